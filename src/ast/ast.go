@@ -3,11 +3,15 @@ package ast
 import (
 	"af/src/token"
 	"bytes"
+	"math/big"
+	"strconv"
+	"strings"
 )
 
 type Node interface {
 	TokenLiteral() string
 	PrintAsString() string
+	Span() token.Span // region of the source the node was parsed from
 }
 
 type Statement interface {
@@ -32,6 +36,13 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Span() token.Span {
+	if len(p.Statements) == 0 {
+		return token.Span{}
+	}
+	return token.Span{Start: p.Statements[0].Span().Start, End: p.Statements[len(p.Statements)-1].Span().End}
+}
+
 func (p *Program) PrintAsString() string {
 	var out bytes.Buffer
 
@@ -41,10 +52,15 @@ func (p *Program) PrintAsString() string {
 	return out.String()
 }
 
+// also used for const declarations, in which case Constant is true.
+// Pattern is set instead of Name when the value is destructured, like let [a, b] = pair
 type LetStatement struct {
-	Token token.Token
-	Name  *Identifier
-	Value Expression
+	Token    token.Token
+	Name     *Identifier
+	Pattern  Pattern
+	Value    Expression
+	Constant bool
+	Doc      string // text of the /// comments written before the declaration
 }
 
 type Identifier struct {
@@ -56,12 +72,26 @@ func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
 
+func (ls *LetStatement) Span() token.Span {
+	if ls.Value != nil {
+		return spanTo(ls.Token, ls.Value)
+	}
+	if ls.Pattern != nil {
+		return spanTo(ls.Token, ls.Pattern)
+	}
+	return spanTo(ls.Token, ls.Name)
+}
+
 func (ls *LetStatement) statementNode() {}
 
 func (ls *LetStatement) PrintAsString() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.PrintAsString() + " ")
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.PrintAsString())
+	} else {
+		out.WriteString(ls.Name.PrintAsString())
+	}
 	out.WriteString(" = ")
 	if ls.Value != nil {
 		out.WriteString(ls.Value.PrintAsString())
@@ -74,6 +104,11 @@ func (i *Identifier) expressionNode() {}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
+
+func (i *Identifier) Span() token.Span {
+	return i.Token.Span
+}
+
 func (i *Identifier) PrintAsString() string {
 	return i.Value
 }
@@ -87,6 +122,10 @@ func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
 
+func (rs *ReturnStatement) Span() token.Span {
+	return spanTo(rs.Token, rs.ReturnValue)
+}
+
 func (rs *ReturnStatement) statementNode() {}
 func (rs *ReturnStatement) PrintAsString() string {
 	var out bytes.Buffer
@@ -107,6 +146,10 @@ func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
 
+func (es *ExpressionStatement) Span() token.Span {
+	return spanTo(es.Token, es.Expression)
+}
+
 func (es *ExpressionStatement) statementNode() {}
 
 func (es *ExpressionStatement) PrintAsString() string {
@@ -125,12 +168,36 @@ func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
 
+func (il *IntegerLiteral) Span() token.Span {
+	return il.Token.Span
+}
+
 func (il *IntegerLiteral) expressionNode() {}
 
 func (il *IntegerLiteral) PrintAsString() string {
 	return il.Token.Literal
 }
 
+// integer literal too large for an int64
+type BigIntegerLiteral struct {
+	Token token.Token
+	Value *big.Int
+}
+
+func (bl *BigIntegerLiteral) TokenLiteral() string {
+	return bl.Token.Literal
+}
+
+func (bl *BigIntegerLiteral) Span() token.Span {
+	return bl.Token.Span
+}
+
+func (bl *BigIntegerLiteral) expressionNode() {}
+
+func (bl *BigIntegerLiteral) PrintAsString() string {
+	return bl.Token.Literal
+}
+
 type FloatLiteral struct {
 	Token token.Token
 	Value float64
@@ -140,6 +207,10 @@ func (fl *FloatLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
 
+func (fl *FloatLiteral) Span() token.Span {
+	return fl.Token.Span
+}
+
 func (fl *FloatLiteral) expressionNode() {}
 
 func (fl *FloatLiteral) PrintAsString() string {
@@ -156,6 +227,10 @@ func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
 
+func (pe *PrefixExpression) Span() token.Span {
+	return spanTo(pe.Token, pe.Right)
+}
+
 func (pe *PrefixExpression) expressionNode() {}
 
 func (pe *PrefixExpression) PrintAsString() string {
@@ -179,6 +254,10 @@ func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
 
+func (ie *InfixExpression) Span() token.Span {
+	return spanBetween(ie.Left, ie.Right)
+}
+
 func (ie *InfixExpression) expressionNode() {}
 
 func (ie *InfixExpression) PrintAsString() string {
@@ -201,8 +280,685 @@ func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
 
+func (b *Boolean) Span() token.Span {
+	return b.Token.Span
+}
+
 func (b *Boolean) expressionNode() {}
 
 func (b *Boolean) PrintAsString() string {
 	return b.Token.Literal
 }
+
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) TokenLiteral() string {
+	return sl.Token.Literal
+}
+
+func (sl *StringLiteral) Span() token.Span {
+	return sl.Token.Span
+}
+
+func (sl *StringLiteral) expressionNode() {}
+
+func (sl *StringLiteral) PrintAsString() string {
+	return strconv.Quote(sl.Value)
+}
+
+// result of a string with ${...} interpolations, its parts are joined at runtime
+type ConcatExpression struct {
+	Token token.Token
+	Parts []Expression
+}
+
+func (ce *ConcatExpression) TokenLiteral() string {
+	return ce.Token.Literal
+}
+
+func (ce *ConcatExpression) Span() token.Span {
+	return ce.Token.Span
+}
+
+func (ce *ConcatExpression) expressionNode() {}
+
+func (ce *ConcatExpression) PrintAsString() string {
+	var out bytes.Buffer
+	parts := []string{}
+	for _, part := range ce.Parts {
+		parts = append(parts, part.PrintAsString())
+	}
+	out.WriteString("(")
+	out.WriteString(strings.Join(parts, " + "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type ArrayLiteral struct {
+	Token    token.Token
+	Elements []Expression
+	EndToken token.Token // the ]
+}
+
+func (al *ArrayLiteral) TokenLiteral() string {
+	return al.Token.Literal
+}
+
+func (al *ArrayLiteral) Span() token.Span {
+	return token.Span{Start: al.Token.Span.Start, End: al.EndToken.Span.End}
+}
+
+func (al *ArrayLiteral) expressionNode() {}
+
+func (al *ArrayLiteral) PrintAsString() string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, element := range al.Elements {
+		elements = append(elements, element.PrintAsString())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// Optional is set for left?.[index], which produces null when left is null
+type IndexExpression struct {
+	Token    token.Token
+	Left     Expression
+	Index    Expression
+	Optional bool
+	EndToken token.Token // the ]
+}
+
+func (ie *IndexExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+
+func (ie *IndexExpression) Span() token.Span {
+	return token.Span{Start: ie.Left.Span().Start, End: ie.EndToken.Span.End}
+}
+
+func (ie *IndexExpression) expressionNode() {}
+
+func (ie *IndexExpression) PrintAsString() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ie.Left.PrintAsString())
+	if ie.Optional {
+		out.WriteString("?.")
+	}
+	out.WriteString("[")
+	out.WriteString(ie.Index.PrintAsString())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// arr[start:end], both bounds are optional. Optional is set for arr?.[start:end]
+type SliceExpression struct {
+	Token    token.Token
+	Left     Expression
+	Start    Expression
+	End      Expression
+	Optional bool
+	EndToken token.Token // the ]
+}
+
+func (se *SliceExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+
+func (se *SliceExpression) Span() token.Span {
+	return token.Span{Start: se.Left.Span().Start, End: se.EndToken.Span.End}
+}
+
+func (se *SliceExpression) expressionNode() {}
+
+func (se *SliceExpression) PrintAsString() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(se.Left.PrintAsString())
+	if se.Optional {
+		out.WriteString("?.")
+	}
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.PrintAsString())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.PrintAsString())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+// pairs keep the order in which they were written
+type HashLiteral struct {
+	Token    token.Token
+	Pairs    []HashPair
+	EndToken token.Token // the }
+}
+
+func (hl *HashLiteral) TokenLiteral() string {
+	return hl.Token.Literal
+}
+
+func (hl *HashLiteral) Span() token.Span {
+	return token.Span{Start: hl.Token.Span.Start, End: hl.EndToken.Span.End}
+}
+
+func (hl *HashLiteral) expressionNode() {}
+
+func (hl *HashLiteral) PrintAsString() string {
+	var out bytes.Buffer
+	pairs := []string{}
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.PrintAsString()+": "+pair.Value.PrintAsString())
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type BlockStatement struct {
+	Token      token.Token
+	Statements []Statement
+	EndToken   token.Token // the }
+}
+
+func (bs *BlockStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+
+func (bs *BlockStatement) Span() token.Span {
+	return token.Span{Start: bs.Token.Span.Start, End: bs.EndToken.Span.End}
+}
+
+func (bs *BlockStatement) statementNode() {}
+
+func (bs *BlockStatement) PrintAsString() string {
+	var out bytes.Buffer
+	out.WriteString("{ ")
+	for _, stmt := range bs.Statements {
+		out.WriteString(stmt.PrintAsString())
+	}
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// Name is empty for anonymous functions. A parameter is a BindingPattern
+// for a plain name, or an array or dictionary pattern destructuring the argument
+type FunctionLiteral struct {
+	Token      token.Token
+	Name       string
+	Parameters []Pattern
+	Body       *BlockStatement
+}
+
+func (fl *FunctionLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+func (fl *FunctionLiteral) Span() token.Span {
+	return spanTo(fl.Token, fl.Body)
+}
+
+func (fl *FunctionLiteral) expressionNode() {}
+
+func (fl *FunctionLiteral) PrintAsString() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, param := range fl.Parameters {
+		params = append(params, param.PrintAsString())
+	}
+	out.WriteString(fl.TokenLiteral())
+	if fl.Name != "" {
+		out.WriteString(" " + fl.Name)
+	}
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(fl.Body.PrintAsString())
+
+	return out.String()
+}
+
+// fn name(params) { } declarations, binds the function to its name in the current scope
+type FunctionStatement struct {
+	Token    token.Token
+	Name     *Identifier
+	Function *FunctionLiteral
+	Doc      string // text of the /// comments written before the declaration
+}
+
+func (fs *FunctionStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+
+func (fs *FunctionStatement) Span() token.Span {
+	return fs.Function.Span()
+}
+
+func (fs *FunctionStatement) statementNode() {}
+
+func (fs *FunctionStatement) PrintAsString() string {
+	return fs.Function.PrintAsString()
+}
+
+type CallExpression struct {
+	Token     token.Token
+	Function  Expression // Identifier or FunctionLiteral
+	Arguments []Expression
+	EndToken  token.Token // the )
+}
+
+func (ce *CallExpression) TokenLiteral() string {
+	return ce.Token.Literal
+}
+
+func (ce *CallExpression) Span() token.Span {
+	return token.Span{Start: ce.Function.Span().Start, End: ce.EndToken.Span.End}
+}
+
+func (ce *CallExpression) expressionNode() {}
+
+func (ce *CallExpression) PrintAsString() string {
+	var out bytes.Buffer
+	args := []string{}
+	for _, arg := range ce.Arguments {
+		args = append(args, arg.PrintAsString())
+	}
+	out.WriteString(ce.Function.PrintAsString())
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// an else if chain is stored as an Alternative block holding the nested IfExpression
+type IfExpression struct {
+	Token       token.Token
+	Condition   Expression
+	Consequence *BlockStatement
+	Alternative *BlockStatement
+}
+
+func (ie *IfExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+
+func (ie *IfExpression) Span() token.Span {
+	if ie.Alternative != nil {
+		return spanTo(ie.Token, ie.Alternative)
+	}
+	return spanTo(ie.Token, ie.Consequence)
+}
+
+func (ie *IfExpression) expressionNode() {}
+
+func (ie *IfExpression) PrintAsString() string {
+	var out bytes.Buffer
+	out.WriteString("if ")
+	out.WriteString(ie.Condition.PrintAsString())
+	out.WriteString(" ")
+	out.WriteString(ie.Consequence.PrintAsString())
+	if ie.Alternative != nil {
+		out.WriteString(" else ")
+		out.WriteString(ie.Alternative.PrintAsString())
+	}
+
+	return out.String()
+}
+
+// &&, || and ?? operators, kept apart from InfixExpression because the right side is evaluated lazily
+type LogicalExpression struct {
+	Token    token.Token
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+func (le *LogicalExpression) TokenLiteral() string {
+	return le.Token.Literal
+}
+
+func (le *LogicalExpression) Span() token.Span {
+	return spanBetween(le.Left, le.Right)
+}
+
+func (le *LogicalExpression) expressionNode() {}
+
+func (le *LogicalExpression) PrintAsString() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(le.Left.PrintAsString())
+	out.WriteString(" " + le.Operator + " ")
+	out.WriteString(le.Right.PrintAsString())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) TokenLiteral() string {
+	return ws.Token.Literal
+}
+
+func (ws *WhileStatement) Span() token.Span {
+	return spanTo(ws.Token, ws.Body)
+}
+
+func (ws *WhileStatement) statementNode() {}
+
+func (ws *WhileStatement) PrintAsString() string {
+	var out bytes.Buffer
+	out.WriteString("while ")
+	out.WriteString(ws.Condition.PrintAsString())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.PrintAsString())
+
+	return out.String()
+}
+
+// for (value in iterable) or for (key, value in iterable), Key is nil in the single variable form
+type ForStatement struct {
+	Token    token.Token
+	Key      *Identifier
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+
+func (fs *ForStatement) Span() token.Span {
+	return spanTo(fs.Token, fs.Body)
+}
+
+func (fs *ForStatement) statementNode() {}
+
+func (fs *ForStatement) PrintAsString() string {
+	var out bytes.Buffer
+	out.WriteString("for (")
+	if fs.Key != nil {
+		out.WriteString(fs.Key.PrintAsString() + ", ")
+	}
+	out.WriteString(fs.Value.PrintAsString())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.PrintAsString())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.PrintAsString())
+
+	return out.String()
+}
+
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+
+func (bs *BreakStatement) Span() token.Span {
+	return bs.Token.Span
+}
+
+func (bs *BreakStatement) statementNode() {}
+
+func (bs *BreakStatement) PrintAsString() string {
+	return bs.TokenLiteral() + ";"
+}
+
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+
+func (cs *ContinueStatement) Span() token.Span {
+	return cs.Token.Span
+}
+
+func (cs *ContinueStatement) statementNode() {}
+
+func (cs *ContinueStatement) PrintAsString() string {
+	return cs.TokenLiteral() + ";"
+}
+
+// Target is an Identifier or an IndexExpression, Operator is = or a compound operator like +=
+type AssignExpression struct {
+	Token    token.Token
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+
+func (ae *AssignExpression) Span() token.Span {
+	return spanBetween(ae.Target, ae.Value)
+}
+
+func (ae *AssignExpression) expressionNode() {}
+
+func (ae *AssignExpression) PrintAsString() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ae.Target.PrintAsString())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.PrintAsString())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type NullLiteral struct {
+	Token token.Token
+}
+
+func (nl *NullLiteral) TokenLiteral() string {
+	return nl.Token.Literal
+}
+
+func (nl *NullLiteral) Span() token.Span {
+	return nl.Token.Span
+}
+
+func (nl *NullLiteral) expressionNode() {}
+
+func (nl *NullLiteral) PrintAsString() string {
+	return nl.Token.Literal
+}
+
+// object.property, Optional is set for object?.property which produces null when object is null
+type MemberExpression struct {
+	Token    token.Token
+	Object   Expression
+	Property *Identifier
+	Optional bool
+}
+
+func (me *MemberExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+
+func (me *MemberExpression) Span() token.Span {
+	return spanBetween(me.Object, me.Property)
+}
+
+func (me *MemberExpression) expressionNode() {}
+
+func (me *MemberExpression) PrintAsString() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(me.Object.PrintAsString())
+	if me.Optional {
+		out.WriteString("?.")
+	} else {
+		out.WriteString(".")
+	}
+	out.WriteString(me.Property.PrintAsString())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// struct Point { x, y }
+type StructStatement struct {
+	Token    token.Token // the struct keyword
+	Name     *Identifier
+	Fields   []*Identifier
+	EndToken token.Token // the }
+	Doc      string      // text of the /// comments written before the declaration
+}
+
+func (ss *StructStatement) TokenLiteral() string {
+	return ss.Token.Literal
+}
+
+func (ss *StructStatement) Span() token.Span {
+	return token.Span{Start: ss.Token.Span.Start, End: ss.EndToken.Span.End}
+}
+
+func (ss *StructStatement) statementNode() {}
+
+func (ss *StructStatement) PrintAsString() string {
+	fields := []string{}
+	for _, field := range ss.Fields {
+		fields = append(fields, field.PrintAsString())
+	}
+	return "struct " + ss.Name.PrintAsString() + " { " + strings.Join(fields, ", ") + " }"
+}
+
+type StructField struct {
+	Name  *Identifier
+	Value Expression
+}
+
+// Point{x: 1, y: 2}, fields are kept in the order they were written
+type StructLiteral struct {
+	Token    token.Token // the {
+	Name     *Identifier
+	Fields   []StructField
+	EndToken token.Token // the }
+}
+
+func (sl *StructLiteral) TokenLiteral() string {
+	return sl.Token.Literal
+}
+
+func (sl *StructLiteral) Span() token.Span {
+	return token.Span{Start: sl.Name.Span().Start, End: sl.EndToken.Span.End}
+}
+
+func (sl *StructLiteral) expressionNode() {}
+
+func (sl *StructLiteral) PrintAsString() string {
+	fields := []string{}
+	for _, field := range sl.Fields {
+		fields = append(fields, field.Name.PrintAsString()+": "+field.Value.PrintAsString())
+	}
+	return sl.Name.PrintAsString() + "{" + strings.Join(fields, ", ") + "}"
+}
+
+// class Name extends Parent { fn init() { } ... }, the body only holds method declarations
+type ClassStatement struct {
+	Token    token.Token // the class keyword
+	Name     *Identifier
+	Parent   *Identifier // nil when the class doesn't extend another one
+	Methods  []*FunctionStatement
+	EndToken token.Token // the }
+	Doc      string      // text of the /// comments written before the declaration
+}
+
+func (cs *ClassStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+
+func (cs *ClassStatement) Span() token.Span {
+	return token.Span{Start: cs.Token.Span.Start, End: cs.EndToken.Span.End}
+}
+
+func (cs *ClassStatement) statementNode() {}
+
+func (cs *ClassStatement) PrintAsString() string {
+	var out bytes.Buffer
+	out.WriteString("class " + cs.Name.PrintAsString())
+	if cs.Parent != nil {
+		out.WriteString(" extends " + cs.Parent.PrintAsString())
+	}
+	out.WriteString(" { ")
+	for _, method := range cs.Methods {
+		out.WriteString(method.PrintAsString() + " ")
+	}
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// super.method, the method of the parent class bound to the current self
+type SuperExpression struct {
+	Token  token.Token // the super keyword
+	Method *Identifier
+}
+
+func (se *SuperExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+
+func (se *SuperExpression) Span() token.Span {
+	return spanTo(se.Token, se.Method)
+}
+
+func (se *SuperExpression) expressionNode() {}
+
+func (se *SuperExpression) PrintAsString() string {
+	return "super." + se.Method.PrintAsString()
+}
+
+// span from the start of tok to the end of last, or just the span of tok when last is missing
+func spanTo(tok token.Token, last Node) token.Span {
+	span := tok.Span
+	if last != nil {
+		span.End = last.Span().End
+	}
+	return span
+}
+
+// span from the start of first to the end of last
+func spanBetween(first, last Node) token.Span {
+	span := first.Span()
+	if last != nil {
+		span.End = last.Span().End
+	}
+	return span
+}
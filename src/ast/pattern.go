@@ -0,0 +1,189 @@
+package ast
+
+import (
+	"af/src/token"
+	"bytes"
+	"strings"
+)
+
+// patterns describe the shape of a value in match arms, let bindings and parameters, matching one can bind names
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// _ matches anything and binds nothing
+type WildcardPattern struct {
+	Token token.Token
+}
+
+func (wp *WildcardPattern) TokenLiteral() string {
+	return wp.Token.Literal
+}
+
+func (wp *WildcardPattern) Span() token.Span {
+	return wp.Token.Span
+}
+
+func (wp *WildcardPattern) patternNode() {}
+
+func (wp *WildcardPattern) PrintAsString() string {
+	return "_"
+}
+
+// a name matches anything and binds the value to it
+type BindingPattern struct {
+	Name *Identifier
+}
+
+func (bp *BindingPattern) TokenLiteral() string {
+	return bp.Name.TokenLiteral()
+}
+
+func (bp *BindingPattern) Span() token.Span {
+	return bp.Name.Span()
+}
+
+func (bp *BindingPattern) patternNode() {}
+
+func (bp *BindingPattern) PrintAsString() string {
+	return bp.Name.PrintAsString()
+}
+
+// matches the values equal to Value, a literal like 1, "a" or null
+type ValuePattern struct {
+	Value Expression
+}
+
+func (vp *ValuePattern) TokenLiteral() string {
+	return vp.Value.TokenLiteral()
+}
+
+func (vp *ValuePattern) Span() token.Span {
+	return vp.Value.Span()
+}
+
+func (vp *ValuePattern) patternNode() {}
+
+func (vp *ValuePattern) PrintAsString() string {
+	return vp.Value.PrintAsString()
+}
+
+// ...name inside an array pattern, binds the elements the other patterns didn't take
+type RestPattern struct {
+	Token token.Token // the ...
+	Name  *Identifier // _ when the elements are ignored
+}
+
+func (rp *RestPattern) TokenLiteral() string {
+	return rp.Token.Literal
+}
+
+func (rp *RestPattern) Span() token.Span {
+	return spanTo(rp.Token, rp.Name)
+}
+
+func (rp *RestPattern) patternNode() {}
+
+func (rp *RestPattern) PrintAsString() string {
+	return "..." + rp.Name.PrintAsString()
+}
+
+// [first, second, ...rest] matches arrays, without a rest pattern the length has to be exact
+type ArrayPattern struct {
+	Token    token.Token // the [
+	Elements []Pattern   // at most one of them is a *RestPattern
+	EndToken token.Token // the ]
+}
+
+func (ap *ArrayPattern) TokenLiteral() string {
+	return ap.Token.Literal
+}
+
+func (ap *ArrayPattern) Span() token.Span {
+	return token.Span{Start: ap.Token.Span.Start, End: ap.EndToken.Span.End}
+}
+
+func (ap *ArrayPattern) patternNode() {}
+
+func (ap *ArrayPattern) PrintAsString() string {
+	elements := []string{}
+	for _, element := range ap.Elements {
+		elements = append(elements, element.PrintAsString())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+type HashPatternPair struct {
+	Key   Expression // literal key, {name} and {name: p} use the string "name"
+	Value Pattern
+}
+
+// {"key": pattern, name} matches dictionaries having every key, other keys are ignored.
+// Name keys also match the fields of structs and instances
+type HashPattern struct {
+	Token    token.Token // the {
+	Pairs    []HashPatternPair
+	EndToken token.Token // the }
+}
+
+func (hp *HashPattern) TokenLiteral() string {
+	return hp.Token.Literal
+}
+
+func (hp *HashPattern) Span() token.Span {
+	return token.Span{Start: hp.Token.Span.Start, End: hp.EndToken.Span.End}
+}
+
+func (hp *HashPattern) patternNode() {}
+
+func (hp *HashPattern) PrintAsString() string {
+	pairs := []string{}
+	for _, pair := range hp.Pairs {
+		pairs = append(pairs, pair.Key.PrintAsString()+": "+pair.Value.PrintAsString())
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Expression // nil when the arm has no if
+	Body    Expression
+}
+
+// match (value) { pattern if guard => body, ... }, the first matching arm is evaluated
+type MatchExpression struct {
+	Token    token.Token // the match keyword
+	Value    Expression
+	Arms     []MatchArm
+	EndToken token.Token // the }
+}
+
+func (me *MatchExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+
+func (me *MatchExpression) Span() token.Span {
+	return token.Span{Start: me.Token.Span.Start, End: me.EndToken.Span.End}
+}
+
+func (me *MatchExpression) expressionNode() {}
+
+func (me *MatchExpression) PrintAsString() string {
+	var out bytes.Buffer
+	arms := []string{}
+	for _, arm := range me.Arms {
+		text := arm.Pattern.PrintAsString()
+		if arm.Guard != nil {
+			text += " if " + arm.Guard.PrintAsString()
+		}
+		arms = append(arms, text+" => "+arm.Body.PrintAsString())
+	}
+	out.WriteString("match (")
+	out.WriteString(me.Value.PrintAsString())
+	out.WriteString(") { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
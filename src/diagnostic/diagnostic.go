@@ -0,0 +1,196 @@
+package diagnostic
+
+import (
+	"af/src/token"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+// stable identifier of a kind of problem, messages may change but codes don't
+type Code string
+
+const (
+	// Lexer
+	UnexpectedCharacter Code = "E0001"
+	UnterminatedString  Code = "E0002"
+	UnterminatedComment Code = "E0003"
+	MalformedNumber     Code = "E0004"
+
+	// Parser
+	UnexpectedToken         Code = "E0100"
+	ExpectedExpression      Code = "E0101"
+	UnclosedBlock           Code = "E0102"
+	InvalidAssignmentTarget Code = "E0103"
+	InvalidLiteral          Code = "E0104"
+	InvalidInterpolation    Code = "E0105"
+	LoopControlOutsideLoop  Code = "E0106"
+	AssignToConstant        Code = "E0107"
+	RedeclaredConstant      Code = "E0108"
+	TooManyErrors           Code = "E0109"
+	DuplicateField          Code = "E0110"
+	DuplicateMethod         Code = "E0111"
+	SuperOutsideSubclass    Code = "E0112"
+	DuplicateBinding        Code = "E0113"
+	InvalidPattern          Code = "E0114"
+
+	// Runtime
+	TypeMismatch         Code = "E0200"
+	UnknownOperator      Code = "E0201"
+	UndefinedIdentifier  Code = "E0202"
+	DivisionByZero       Code = "E0203"
+	InvalidIndex         Code = "E0204"
+	UnhashableKey        Code = "E0205"
+	NotCallable          Code = "E0206"
+	WrongArgumentCount   Code = "E0207"
+	NotIterable          Code = "E0208"
+	InvalidMember        Code = "E0209"
+	ConstantAssignment   Code = "E0210"
+	UndeclaredAssignment Code = "E0211"
+	NotAStruct           Code = "E0212"
+	UnknownField         Code = "E0213"
+	MissingField         Code = "E0214"
+	NotAClass            Code = "E0215"
+	PatternMismatch      Code = "E0216"
+	IntegerTooLarge      Code = "E0217"
+
+	// Warnings
+	NonExhaustiveMatch Code = "W0001"
+)
+
+// a span of source with a short explanation, like where a constant was declared
+type Label struct {
+	Span    token.Span
+	Message string
+}
+
+// a problem found in the source by the lexer, the parser or the evaluator.
+// A zero Span means the problem has no location, like reaching the error limit
+type Diagnostic struct {
+	Severity  Severity
+	Code      Code
+	Message   string
+	Span      token.Span // primary location of the problem
+	Secondary []Label    // related locations
+	Help      string     // suggestion on how to fix the problem, may be empty
+}
+
+func Errorf(code Code, span token.Span, format string, a ...interface{}) Diagnostic {
+	return Diagnostic{Severity: Error, Code: code, Span: span, Message: fmt.Sprintf(format, a...)}
+}
+
+func (d Diagnostic) HasSpan() bool {
+	return d.Span.Start.Line > 0
+}
+
+// line:column: message, the short form used in plain text output
+func (d Diagnostic) String() string {
+	if !d.HasSpan() {
+		return d.Message
+	}
+	return d.Span.Start.String() + ": " + d.Message
+}
+
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Render formats the diagnostic with the source lines it points at, the primary span
+// is underlined with ^~~~ and secondary spans with ~~~ followed by their message:
+//
+//	error[E0107]: cannot assign to constant x
+//	 --> 1:14
+//	1 | const x = 1; x = 2
+//	  |              ^~~~~
+//	1 | const x = 1; x = 2
+//	  |       ~ constant declared here
+func (d Diagnostic) Render(source string) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "%s[%s]: %s\n", d.Severity, d.Code, d.Message)
+	if d.HasSpan() {
+		width := len(strconv.Itoa(d.Span.Start.Line))
+		for _, label := range d.Secondary {
+			width = max(width, len(strconv.Itoa(label.Span.Start.Line)))
+		}
+		fmt.Fprintf(&out, "%s--> %s\n", strings.Repeat(" ", width), d.Span.Start)
+		renderSpan(&out, source, width, d.Span, '^', "")
+		for _, label := range d.Secondary {
+			renderSpan(&out, source, width, label.Span, '~', label.Message)
+		}
+	}
+	if d.Help != "" {
+		fmt.Fprintf(&out, "help: %s\n", d.Help)
+	}
+	return out.String()
+}
+
+func RenderAll(source string, diagnostics []Diagnostic) string {
+	var out strings.Builder
+	for _, d := range diagnostics {
+		out.WriteString(d.Render(source))
+	}
+	return out.String()
+}
+
+// prints the line where span starts and underlines the span in it, a span going
+// over several lines is underlined until the end of its first line
+func renderSpan(out *strings.Builder, source string, width int, span token.Span, first rune, message string) {
+	line := sourceLine(source, span.Start.Offset)
+	runes := []rune(line)
+	column := min(span.Start.Column-1, len(runes))
+	length := len(runes) - column
+	if span.End.Line == span.Start.Line {
+		length = min(span.End.Column-span.Start.Column, length)
+	}
+	length = max(length, 1)
+
+	var underline strings.Builder
+	// tabs are copied so the underline lines up with the source however tabs are displayed
+	for _, char := range runes[:column] {
+		if char == '\t' {
+			underline.WriteRune('\t')
+		} else {
+			underline.WriteRune(' ')
+		}
+	}
+	underline.WriteRune(first)
+	underline.WriteString(strings.Repeat("~", length-1))
+	if message != "" {
+		underline.WriteString(" " + message)
+	}
+
+	fmt.Fprintf(out, "%*d | %s\n", width, span.Start.Line, line)
+	fmt.Fprintf(out, "%s | %s\n", strings.Repeat(" ", width), underline.String())
+}
+
+// the line of source containing offset, without its line break
+func sourceLine(source string, offset int) string {
+	offset = max(0, min(offset, len(source)))
+	start := strings.LastIndexAny(source[:offset], "\r\n") + 1
+	end := strings.IndexAny(source[offset:], "\r\n")
+	if end < 0 {
+		return source[start:]
+	}
+	return source[start : offset+end]
+}
@@ -0,0 +1,89 @@
+package diagnostic
+
+import (
+	"af/src/token"
+	"testing"
+)
+
+// span of length chars starting at offset, source has to be a single line
+func lineSpan(offset, length int) token.Span {
+	start := token.Position{Offset: offset, Line: 1, Column: offset + 1}
+	end := start
+	end.Offset += length
+	end.Column += length
+	return token.Span{Start: start, End: end}
+}
+
+func TestRender(t *testing.T) {
+	source := "const x = 1; x = 2"
+	d := Errorf(AssignToConstant, lineSpan(13, 5), "cannot assign to constant %s", "x")
+	d.Secondary = []Label{{Span: lineSpan(6, 1), Message: "constant declared here"}}
+	d.Help = "declare x with let if it has to change"
+
+	expected := `error[E0107]: cannot assign to constant x
+ --> 1:14
+1 | const x = 1; x = 2
+  |              ^~~~~
+1 | const x = 1; x = 2
+  |       ~ constant declared here
+help: declare x with let if it has to change
+`
+	if rendered := d.Render(source); rendered != expected {
+		t.Errorf("wrong rendering.\nexpected:\n%s\ngot:\n%s", expected, rendered)
+	}
+}
+
+func TestRenderLines(t *testing.T) {
+	source := "let a = 1;\r\n\tlet b = \"añ\" +\n  2;\n\n\n\n\n\n\n\nlet c = ?"
+
+	tests := []struct {
+		span     token.Span
+		expected string
+	}{
+		{
+			// tabs are kept and columns count runes
+			token.Span{Start: token.Position{Offset: 13, Line: 2, Column: 2}, End: token.Position{Offset: 16, Line: 2, Column: 5}},
+			"error[E0001]: problem\n --> 2:2\n2 | \tlet b = \"añ\" +\n  | \t^~~\n",
+		},
+		{
+			// a span over several lines is underlined until the end of the first one
+			token.Span{Start: token.Position{Offset: 21, Line: 2, Column: 10}, End: token.Position{Offset: 31, Line: 3, Column: 4}},
+			"error[E0001]: problem\n --> 2:10\n2 | \tlet b = \"añ\" +\n  | \t        ^~~~~~\n",
+		},
+		{
+			// an empty span at the end of the input still gets a caret
+			token.Span{Start: token.Position{Offset: 50, Line: 11, Column: 10}, End: token.Position{Offset: 50, Line: 11, Column: 10}},
+			"error[E0001]: problem\n  --> 11:10\n11 | let c = ?\n   |          ^\n",
+		},
+	}
+
+	for _, tt := range tests {
+		d := Errorf(UnexpectedCharacter, tt.span, "problem")
+		if rendered := d.Render(source); rendered != tt.expected {
+			t.Errorf("wrong rendering.\nexpected:\n%q\ngot:\n%q", tt.expected, rendered)
+		}
+	}
+}
+
+func TestRenderWithoutSpan(t *testing.T) {
+	d := Errorf(TooManyErrors, token.Span{}, "too many errors, stopping after %d", 25)
+	if rendered := d.Render("let a = 1"); rendered != "error[E0109]: too many errors, stopping after 25\n" {
+		t.Errorf("wrong rendering, got=%q", rendered)
+	}
+	if d.String() != "too many errors, stopping after 25" {
+		t.Errorf("wrong string, got=%q", d.String())
+	}
+}
+
+func TestHasErrors(t *testing.T) {
+	warning := Diagnostic{Severity: Warning, Code: UnexpectedToken, Message: "careful"}
+	if HasErrors([]Diagnostic{warning}) {
+		t.Errorf("a warning is not an error")
+	}
+	if !HasErrors([]Diagnostic{warning, Errorf(UnexpectedToken, token.Span{}, "broken")}) {
+		t.Errorf("expected errors to be found")
+	}
+	if warning.Severity.String() != "warning" || Error.String() != "error" {
+		t.Errorf("wrong severity names")
+	}
+}
@@ -0,0 +1,94 @@
+package evaluator
+
+import (
+	"af/src/ast"
+	"af/src/diagnostic"
+	"af/src/object"
+)
+
+// methods are closures over the scope of the class statement, like functions
+func evalClassStatement(node *ast.ClassStatement, env *object.Environment) object.Object {
+	class := &object.Class{Name: node.Name.Value, Methods: make(map[string]*object.Function)}
+	if node.Parent != nil {
+		parent := evalIdentifier(node.Parent, env)
+		if isError(parent) {
+			return parent
+		}
+		parentClass, ok := parent.(*object.Class)
+		if !ok {
+			err := newError(diagnostic.NotAClass, "cannot extend %s, it is not a class", parent.Type())
+			err.Span = node.Parent.Span()
+			return err
+		}
+		class.Parent = parentClass
+	}
+	for _, method := range node.Methods {
+		class.Methods[method.Name.Value] = &object.Function{
+			Parameters: method.Function.Parameters,
+			Body:       method.Function.Body,
+			Env:        env,
+			Name:       method.Name.Value,
+		}
+	}
+	if err := declare(env, node.Name.Value, class, false); err != nil {
+		return err
+	}
+	return NULL
+}
+
+// calling a class creates an instance and passes the arguments to init,
+// a class without init in its ancestors takes no arguments
+func instantiate(class *object.Class, args []object.Object) object.Object {
+	instance := object.NewInstance(class)
+	init, owner := class.FindMethod("init")
+	if init == nil {
+		if len(args) != 0 {
+			return newError(diagnostic.WrongArgumentCount, "wrong number of arguments: want=0, got=%d", len(args))
+		}
+		return instance
+	}
+	result := applyMethod(&object.BoundMethod{Receiver: instance, Method: init, Class: owner}, args)
+	if isError(result) {
+		return result
+	}
+	return instance
+}
+
+// self is the receiver and super the parent of the class defining the method,
+// so super calls keep going up the hierarchy from where they are written
+func applyMethod(method *object.BoundMethod, args []object.Object) object.Object {
+	env := object.NewEnclosedEnvironment(method.Method.Env)
+	env.Set("self", method.Receiver)
+	if method.Class.Parent != nil {
+		env.Set("super", method.Class.Parent)
+	}
+	return callFunction(method.Method, env, args)
+}
+
+// fields take priority over methods, methods are resolved when they are accessed
+// so a subclass overriding a method changes it for the methods of its parents too
+func evalInstanceMember(instance *object.Instance, name string) object.Object {
+	if value, ok := instance.Fields[name]; ok {
+		return value
+	}
+	if method, owner := instance.Class.FindMethod(name); method != nil {
+		return &object.BoundMethod{Receiver: instance, Method: method, Class: owner}
+	}
+	return newError(diagnostic.UnknownField, "%s has no field or method %s", instance.Class.Name, name)
+}
+
+// super is a keyword so the binding made by applyMethod can't be shadowed by scripts
+func evalSuperExpression(node *ast.SuperExpression, env *object.Environment) object.Object {
+	parent, ok := env.Get("super")
+	self, hasSelf := env.Get("self")
+	instance, isInstance := self.(*object.Instance)
+	if !ok || !hasSelf || !isInstance {
+		return newError(diagnostic.SuperOutsideSubclass, "super can only be used in the methods of a class that extends another")
+	}
+	parentClass := parent.(*object.Class)
+	method, owner := parentClass.FindMethod(node.Method.Value)
+	if method == nil {
+		return newError(diagnostic.UnknownField, "%s has no method %s", parentClass.Name, node.Method.Value)
+	}
+	return &object.BoundMethod{Receiver: instance, Method: method, Class: owner}
+}
@@ -0,0 +1,1029 @@
+package evaluator
+
+import (
+	"af/src/ast"
+	"af/src/diagnostic"
+	"af/src/object"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// only one instance is needed for these values, so they are compared by pointer
+var (
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
+)
+
+// runtime errors get the span of the innermost node that produced them
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := eval(node, env)
+	setErrorSpan(result, node)
+	return result
+}
+
+func setErrorSpan(result object.Object, node ast.Node) {
+	if err, ok := result.(*object.Error); ok && err.Span.Start.Line == 0 {
+		err.Span = node.Span()
+	}
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	// Statements
+	case *ast.Program:
+		return evalProgram(node, env)
+	case *ast.ExpressionStatement:
+		return Eval(node.Expression, env)
+	case *ast.LetStatement:
+		value := Eval(node.Value, env)
+		if isError(value) || isSignal(value) {
+			return value
+		}
+		if node.Pattern != nil {
+			if err := bindPattern(node.Pattern, value, "value", env, node.Constant); err != nil {
+				return err
+			}
+		} else if err := declare(env, node.Name.Value, value, node.Constant); err != nil {
+			return err
+		}
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env)
+	case *ast.FunctionStatement:
+		function := Eval(node.Function, env)
+		if err := declare(env, node.Name.Value, function, false); err != nil {
+			return err
+		}
+	case *ast.StructStatement:
+		return evalStructStatement(node, env)
+	case *ast.ClassStatement:
+		return evalClassStatement(node, env)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
+	case *ast.ReturnStatement:
+		value := Eval(node.ReturnValue, env)
+		if isError(value) || isSignal(value) {
+			return value
+		}
+		return &object.ReturnValue{Value: value}
+
+	// Expressions
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}
+	case *ast.BigIntegerLiteral:
+		return &object.BigInteger{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *ast.ConcatExpression:
+		return evalConcatExpression(node, env)
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+	case *ast.NullLiteral:
+		return NULL
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && (isError(elements[0]) || isSignal(elements[0])) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+	case *ast.LogicalExpression:
+		return evalLogicalExpression(node, env)
+	case *ast.IfExpression:
+		return evalIfExpression(node, env)
+	case *ast.FunctionLiteral:
+		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env, Name: node.Name}
+	case *ast.CallExpression, *ast.IndexExpression, *ast.SliceExpression, *ast.MemberExpression:
+		result, _ := evalChain(node.(ast.Expression), env)
+		return result
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+	case *ast.StructLiteral:
+		return evalStructLiteral(node, env)
+	case *ast.SuperExpression:
+		return evalSuperExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+	case *ast.PrefixExpression:
+		right := Eval(node.Right, env)
+		if isError(right) || isSignal(right) {
+			return right
+		}
+		return evalPrefixExpression(node.Operator, right)
+	case *ast.InfixExpression:
+		left := Eval(node.Left, env)
+		if isError(left) || isSignal(left) {
+			return left
+		}
+		right := Eval(node.Right, env)
+		if isError(right) || isSignal(right) {
+			return right
+		}
+		return evalInfixExpression(node.Operator, left, right)
+	}
+	return NULL
+}
+
+// binds a name declared by a statement in the current scope. A constant of the same scope
+// can't be declared again, the parser only sees that inside a single program and not across REPL lines
+func declare(env *object.Environment, name string, value object.Object, constant bool) *object.Error {
+	if env.IsLocalConstant(name) {
+		return newError(diagnostic.ConstantAssignment, "cannot redeclare constant %s", name)
+	}
+	if constant {
+		env.SetConstant(name, value)
+	} else {
+		env.Set(name, value)
+	}
+	return nil
+}
+
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object = NULL
+	for _, stmt := range program.Statements {
+		result = Eval(stmt, env)
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		}
+	}
+	return result
+}
+
+// unlike evalProgram, return values are not unwrapped so they stop every enclosing block,
+// break and continue signals also stop the block and are handled by the enclosing loop
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object = NULL
+	for _, stmt := range block.Statements {
+		result = Eval(stmt, env)
+		switch result.(type) {
+		case *object.ReturnValue, *object.Error, *object.Break, *object.Continue:
+			return result
+		}
+	}
+	return result
+}
+
+// like for loops, every iteration gets its own environment, so names declared in the body
+// are new on each iteration and don't leak out of the loop
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isError(condition) || isSignal(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+		result := Eval(ws.Body, object.NewEnclosedEnvironment(env))
+		switch result.(type) {
+		case *object.ReturnValue, *object.Error:
+			return result
+		case *object.Break:
+			return NULL
+		}
+	}
+}
+
+// every iteration gets its own environment so loop variables don't leak and closures
+// capture the value of their iteration
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) || isSignal(iterable) {
+		return iterable
+	}
+	keys, values, err := iterationPairs(iterable)
+	if err != nil {
+		return err
+	}
+	if fs.Key == nil && iterable.Type() == object.HASH_OBJ {
+		values = keys
+	}
+
+	for i := range values {
+		loopEnv := object.NewEnclosedEnvironment(env)
+		if fs.Key != nil {
+			loopEnv.Set(fs.Key.Value, keys[i])
+		}
+		loopEnv.Set(fs.Value.Value, values[i])
+
+		result := Eval(fs.Body, loopEnv)
+		switch result.(type) {
+		case *object.ReturnValue, *object.Error:
+			return result
+		case *object.Break:
+			return NULL
+		}
+	}
+	return NULL
+}
+
+// returns the pairs bound to the loop variables on every iteration. Arrays and strings give
+// index and element, dictionaries give key and value. With a single variable the loop takes
+// the element for arrays and strings and the key for dictionaries
+func iterationPairs(iterable object.Object) ([]object.Object, []object.Object, *object.Error) {
+	keys := []object.Object{}
+	values := []object.Object{}
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		for i, element := range iterable.Elements {
+			keys = append(keys, &object.Integer{Value: int64(i)})
+			values = append(values, element)
+		}
+	case *object.String:
+		for i, char := range []rune(iterable.Value) {
+			keys = append(keys, &object.Integer{Value: int64(i)})
+			values = append(values, &object.String{Value: string(char)})
+		}
+	case *object.Hash:
+		for _, hashKey := range iterable.Keys {
+			pair := iterable.Pairs[hashKey]
+			keys = append(keys, pair.Key)
+			values = append(values, pair.Value)
+		}
+	default:
+		return nil, nil, newError(diagnostic.NotIterable, "cannot iterate over %s", iterable.Type())
+	}
+	return keys, values, nil
+}
+
+// the right side is only evaluated when the left one does not decide the result,
+// both operators produce a boolean based on the truthiness of their operands
+func evalLogicalExpression(le *ast.LogicalExpression, env *object.Environment) object.Object {
+	left := Eval(le.Left, env)
+	if isError(left) || isSignal(left) {
+		return left
+	}
+	switch le.Operator {
+	case "&&":
+		if !isTruthy(left) {
+			return FALSE
+		}
+	case "||":
+		if isTruthy(left) {
+			return TRUE
+		}
+	case "??":
+		// unlike the boolean operators, ?? produces one of its operands
+		if left != NULL {
+			return left
+		}
+		return Eval(le.Right, env)
+	default:
+		return newError(diagnostic.UnknownOperator, "unknown operator: %s", le.Operator)
+	}
+
+	right := Eval(le.Right, env)
+	if isError(right) || isSignal(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+// the target is evaluated before the assigned value
+func evalAssignExpression(ae *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := ae.Target.(type) {
+	case *ast.Identifier:
+		var current object.Object
+		if ae.Operator != "=" {
+			current = evalIdentifier(target, env)
+			if isError(current) {
+				return current
+			}
+		}
+		if env.IsConstant(target.Value) {
+			return newError(diagnostic.ConstantAssignment, "cannot assign to constant %s", target.Value)
+		}
+		value := evalAssignedValue(ae, current, env)
+		if isError(value) || isSignal(value) {
+			return value
+		}
+		if !env.Assign(target.Value, value) {
+			return newError(diagnostic.UndeclaredAssignment, "assignment to undeclared identifier: %s", target.Value)
+		}
+		return value
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) || isSignal(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isError(index) || isSignal(index) {
+			return index
+		}
+		var current object.Object
+		if ae.Operator != "=" {
+			current = evalIndexExpression(left, index)
+			if isError(current) {
+				return current
+			}
+		}
+		value := evalAssignedValue(ae, current, env)
+		if isError(value) || isSignal(value) {
+			return value
+		}
+		return evalIndexAssignment(left, index, value)
+	case *ast.MemberExpression:
+		obj := Eval(target.Object, env)
+		if isError(obj) || isSignal(obj) {
+			return obj
+		}
+		var current object.Object
+		if ae.Operator != "=" {
+			current = evalMember(obj, target.Property.Value)
+			if isError(current) {
+				return current
+			}
+		}
+		value := evalAssignedValue(ae, current, env)
+		if isError(value) || isSignal(value) {
+			return value
+		}
+		return evalMemberAssignment(obj, target.Property.Value, value)
+	default:
+		return newError(diagnostic.InvalidAssignmentTarget, "invalid assignment target: %s", ae.Target.PrintAsString())
+	}
+}
+
+// for compound operators x += y is evaluated as x = x + y
+func evalAssignedValue(ae *ast.AssignExpression, current object.Object, env *object.Environment) object.Object {
+	value := Eval(ae.Value, env)
+	if isError(value) || isSignal(value) || ae.Operator == "=" {
+		return value
+	}
+	return evalInfixExpression(strings.TrimSuffix(ae.Operator, "="), current, value)
+}
+
+func evalIndexAssignment(left, index, value object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		if _, ok := index.(*object.BigInteger); ok {
+			return newError(diagnostic.InvalidIndex, "index out of range: %s with length %d", index.Inspect(), len(left.Elements))
+		}
+		position, ok := index.(*object.Integer)
+		if !ok {
+			return newError(diagnostic.InvalidIndex, "index operator not supported: %s[%s]", left.Type(), index.Type())
+		}
+		length := int64(len(left.Elements))
+		i := position.Value
+		if i < 0 {
+			i += length
+		}
+		if i < 0 || i >= length {
+			return newError(diagnostic.InvalidIndex, "index out of range: %d with length %d", position.Value, length)
+		}
+		left.Elements[i] = value
+		return value
+	case *object.Hash:
+		if _, ok := index.(object.Hashable); !ok {
+			return newError(diagnostic.UnhashableKey, "unusable as hash key: %s", index.Type())
+		}
+		left.Set(index, value)
+		return value
+	default:
+		return newError(diagnostic.InvalidIndex, "index assignment not supported: %s[%s]", left.Type(), index.Type())
+	}
+}
+
+// struct fields have to exist already, instances and dictionaries get the member added if it is missing
+func evalMemberAssignment(obj object.Object, name string, value object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.Struct:
+		if !obj.Definition.HasField(name) {
+			return unknownFieldError(obj.Definition, name)
+		}
+		obj.Fields[name] = value
+		return value
+	case *object.Instance:
+		obj.Set(name, value)
+		return value
+	case *object.Hash:
+		obj.Set(&object.String{Value: name}, value)
+		return value
+	default:
+		return newError(diagnostic.InvalidMember, "member assignment not supported: %s.%s", obj.Type(), name)
+	}
+}
+
+// an if without else whose condition is falsy evaluates to null
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := Eval(ie.Condition, env)
+	if isError(condition) || isSignal(condition) {
+		return condition
+	}
+	if isTruthy(condition) {
+		return Eval(ie.Consequence, env)
+	} else if ie.Alternative != nil {
+		return Eval(ie.Alternative, env)
+	}
+	return NULL
+}
+
+func applyFunction(fn object.Object, args []object.Object) object.Object {
+	switch function := fn.(type) {
+	case *object.Function:
+		return callFunction(function, object.NewEnclosedEnvironment(function.Env), args)
+	case *object.Builtin:
+		return function.Fn(args...)
+	case *object.BoundMethod:
+		return applyMethod(function, args)
+	case *object.Class:
+		return instantiate(function, args)
+	default:
+		return newError(diagnostic.NotCallable, "not a function: %s", fn.Type())
+	}
+}
+
+// binds the arguments in env, a new scope enclosed by the one the function was defined in
+func callFunction(function *object.Function, env *object.Environment, args []object.Object) object.Object {
+	if len(args) != len(function.Parameters) {
+		return newError(diagnostic.WrongArgumentCount, "wrong number of arguments: want=%d, got=%d", len(function.Parameters), len(args))
+	}
+
+	for i, param := range function.Parameters {
+		if err := bindPattern(param, args[i], fmt.Sprintf("argument %d", i+1), env, false); err != nil {
+			return err
+		}
+	}
+	evaluated := Eval(function.Body, env)
+	return unwrapReturnValue(evaluated)
+}
+
+func unwrapReturnValue(obj object.Object) object.Object {
+	if returnValue, ok := obj.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+	return obj
+}
+
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	value, ok := env.Get(node.Value)
+	if !ok {
+		return newError(diagnostic.UndefinedIdentifier, "identifier not found: %s", node.Value)
+	}
+	return value
+}
+
+func evalPrefixExpression(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return nativeBoolToBooleanObject(!isTruthy(right))
+	case "-":
+		return evalMinusPrefixOperatorExpression(right)
+	default:
+		return newError(diagnostic.UnknownOperator, "unknown operator: %s%s", operator, right.Type())
+	}
+}
+
+func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return newInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInteger:
+		return newInteger(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError(diagnostic.UnknownOperator, "unknown operator: -%s", right.Type())
+	}
+}
+
+func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, toFloat(left), toFloat(right))
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
+	case left.Type() == object.STRUCT_OBJ && right.Type() == object.STRUCT_OBJ && (operator == "==" || operator == "!="):
+		equal := structsEqual(left.(*object.Struct), right.(*object.Struct))
+		return nativeBoolToBooleanObject(equal == (operator == "=="))
+	case operator == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	case left.Type() != right.Type():
+		return newError(diagnostic.TypeMismatch, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	default:
+		return newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// int64 operands are computed directly, the big.Int path is taken when an operand
+// is a BigInteger or the int64 result would overflow
+func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	smallLeft, leftOk := left.(*object.Integer)
+	smallRight, rightOk := right.(*object.Integer)
+	if leftOk && rightOk {
+		if result, ok := evalSmallIntegerInfixExpression(operator, smallLeft.Value, smallRight.Value); ok {
+			return result
+		}
+	}
+	return evalBigIntegerInfixExpression(operator, toBigInt(left), toBigInt(right))
+}
+
+// returns false when the operation doesn't fit in an int64
+func evalSmallIntegerInfixExpression(operator string, leftValue, rightValue int64) (object.Object, bool) {
+	switch operator {
+	case "+":
+		result := leftValue + rightValue
+		if (leftValue^result)&(rightValue^result) < 0 {
+			return nil, false
+		}
+		return &object.Integer{Value: result}, true
+	case "-":
+		result := leftValue - rightValue
+		if (leftValue^rightValue)&(leftValue^result) < 0 {
+			return nil, false
+		}
+		return &object.Integer{Value: result}, true
+	case "*":
+		result := leftValue * rightValue
+		if leftValue != 0 && (result/leftValue != rightValue || leftValue == -1 && rightValue == math.MinInt64) {
+			return nil, false
+		}
+		return &object.Integer{Value: result}, true
+	case "/":
+		if rightValue == -1 && leftValue == math.MinInt64 {
+			return nil, false
+		}
+		if rightValue == 0 {
+			return newError(diagnostic.DivisionByZero, "division by zero: %d / 0", leftValue), true
+		}
+		return &object.Integer{Value: leftValue / rightValue}, true
+	case "%":
+		if rightValue == 0 {
+			return newError(diagnostic.DivisionByZero, "division by zero: %d %% 0", leftValue), true
+		}
+		return &object.Integer{Value: leftValue % rightValue}, true
+	case "**":
+		return nil, false
+	case "<":
+		return nativeBoolToBooleanObject(leftValue < rightValue), true
+	case ">":
+		return nativeBoolToBooleanObject(leftValue > rightValue), true
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue), true
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue), true
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue), true
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue), true
+	default:
+		return newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s", object.INTEGER_OBJ, operator, object.INTEGER_OBJ), true
+	}
+}
+
+// a negative exponent gives a float, as the result is a fraction
+func evalBigIntegerInfixExpression(operator string, leftValue, rightValue *big.Int) object.Object {
+	result := new(big.Int)
+	switch operator {
+	case "+":
+		return newInteger(result.Add(leftValue, rightValue))
+	case "-":
+		return newInteger(result.Sub(leftValue, rightValue))
+	case "*":
+		return newInteger(result.Mul(leftValue, rightValue))
+	case "/":
+		if rightValue.Sign() == 0 {
+			return newError(diagnostic.DivisionByZero, "division by zero: %s / 0", leftValue)
+		}
+		return newInteger(result.Quo(leftValue, rightValue))
+	case "%":
+		if rightValue.Sign() == 0 {
+			return newError(diagnostic.DivisionByZero, "division by zero: %s %% 0", leftValue)
+		}
+		return newInteger(result.Rem(leftValue, rightValue))
+	case "**":
+		if rightValue.Sign() < 0 {
+			return &object.Float{Value: math.Pow(bigToFloat(leftValue), bigToFloat(rightValue))}
+		}
+		if powerTooLarge(leftValue, rightValue) {
+			return newError(diagnostic.IntegerTooLarge, "integer too large: %s ** %s has more than %d bits", leftValue, rightValue, maxPowerBits)
+		}
+		return newInteger(result.Exp(leftValue, rightValue, nil))
+	case "<":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) != 0)
+	default:
+		return newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s", object.INTEGER_OBJ, operator, object.INTEGER_OBJ)
+	}
+}
+
+// limit on the size of a power, so a huge exponent is an error instead of using up all the memory
+const maxPowerBits = 1 << 20
+
+// estimates the bits of base ** exponent from the bits of the base, 0, 1 and -1 never grow
+func powerTooLarge(base, exponent *big.Int) bool {
+	if base.CmpAbs(big.NewInt(1)) <= 0 {
+		return false
+	}
+	if !exponent.IsInt64() {
+		return true
+	}
+	return exponent.Int64() > maxPowerBits/int64(base.BitLen()-1)
+}
+
+// integers are promoted to floats when mixed with a float operand
+func evalFloatInfixExpression(operator string, leftValue, rightValue float64) object.Object {
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftValue + rightValue}
+	case "-":
+		return &object.Float{Value: leftValue - rightValue}
+	case "*":
+		return &object.Float{Value: leftValue * rightValue}
+	case "/":
+		if rightValue == 0 {
+			return newError(diagnostic.DivisionByZero, "division by zero: %s / 0", formatFloat(leftValue))
+		}
+		return &object.Float{Value: leftValue / rightValue}
+	case "%":
+		if rightValue == 0 {
+			return newError(diagnostic.DivisionByZero, "division by zero: %s %% 0", formatFloat(leftValue))
+		}
+		return &object.Float{Value: math.Mod(leftValue, rightValue)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftValue, rightValue)}
+	case "<":
+		return nativeBoolToBooleanObject(leftValue < rightValue)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	default:
+		return newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s", object.FLOAT_OBJ, operator, object.FLOAT_OBJ)
+	}
+}
+
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftValue + rightValue}
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	default:
+		return newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// interpolated values are converted with Inspect, strings are inserted as they are
+func evalConcatExpression(node *ast.ConcatExpression, env *object.Environment) object.Object {
+	var out strings.Builder
+	for _, part := range node.Parts {
+		value := Eval(part, env)
+		if isError(value) || isSignal(value) {
+			return value
+		}
+		out.WriteString(value.Inspect())
+	}
+	return &object.String{Value: out.String()}
+}
+
+// evaluates expressions in order, stopping at the first error or signal which is returned alone
+func evalExpressions(expressions []ast.Expression, env *object.Environment) []object.Object {
+	result := []object.Object{}
+	for _, expression := range expressions {
+		evaluated := Eval(expression, env)
+		if isError(evaluated) || isSignal(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+	return result
+}
+
+func evalIndexExpression(left, index object.Object) object.Object {
+	// a big integer is never in range of an array or a string
+	if _, ok := index.(*object.BigInteger); ok && (left.Type() == object.ARRAY_OBJ || left.Type() == object.STRING_OBJ) {
+		return NULL
+	}
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left.(*object.Array), index.(*object.Integer).Value)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left.(*object.String), index.(*object.Integer).Value)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left.(*object.Hash), index)
+	default:
+		return newError(diagnostic.InvalidIndex, "index operator not supported: %s[%s]", left.Type(), index.Type())
+	}
+}
+
+// negative indexes count from the end, out of range indexes produce null
+func evalArrayIndexExpression(array *object.Array, index int64) object.Object {
+	length := int64(len(array.Elements))
+	if index < 0 {
+		index += length
+	}
+	if index < 0 || index >= length {
+		return NULL
+	}
+	return array.Elements[index]
+}
+
+func evalStringIndexExpression(str *object.String, index int64) object.Object {
+	runes := []rune(str.Value)
+	length := int64(len(runes))
+	if index < 0 {
+		index += length
+	}
+	if index < 0 || index >= length {
+		return NULL
+	}
+	return &object.String{Value: string(runes[index])}
+}
+
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if isError(key) || isSignal(key) {
+			return key
+		}
+		if _, ok := key.(object.Hashable); !ok {
+			return newError(diagnostic.UnhashableKey, "unusable as hash key: %s", key.Type())
+		}
+		value := Eval(pair.Value, env)
+		if isError(value) || isSignal(value) {
+			return value
+		}
+		hash.Set(key, value)
+	}
+	return hash
+}
+
+// missing keys produce null
+func evalHashIndexExpression(hash *object.Hash, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError(diagnostic.UnhashableKey, "unusable as hash key: %s", index.Type())
+	}
+	value, ok := hash.Get(key)
+	if !ok {
+		return NULL
+	}
+	return value
+}
+
+// evaluates a call, member, index or slice expression. Once an optional step like config?.db
+// finds null the rest of the chain is skipped, so config?.db.host and s?.upper() are null too.
+// skipped reports that, so the links before it know the chain was cut
+func evalChain(node ast.Expression, env *object.Environment) (result object.Object, skipped bool) {
+	switch node := node.(type) {
+	case *ast.CallExpression:
+		function, skipped := evalChainLink(node.Function, env)
+		if skipped || isError(function) || isSignal(function) {
+			return function, skipped
+		}
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && (isError(args[0]) || isSignal(args[0])) {
+			return args[0], false
+		}
+		return applyFunction(function, args), false
+	case *ast.MemberExpression:
+		obj, skipped := evalChainLink(node.Object, env)
+		if skipped || isError(obj) || isSignal(obj) {
+			return obj, skipped
+		}
+		if node.Optional && obj == NULL {
+			return NULL, true
+		}
+		return evalMember(obj, node.Property.Value), false
+	case *ast.IndexExpression:
+		left, skipped := evalChainLink(node.Left, env)
+		if skipped || isError(left) || isSignal(left) {
+			return left, skipped
+		}
+		if node.Optional && left == NULL {
+			return NULL, true
+		}
+		index := Eval(node.Index, env)
+		if isError(index) || isSignal(index) {
+			return index, false
+		}
+		return evalIndexExpression(left, index), false
+	case *ast.SliceExpression:
+		left, skipped := evalChainLink(node.Left, env)
+		if skipped || isError(left) || isSignal(left) {
+			return left, skipped
+		}
+		if node.Optional && left == NULL {
+			return NULL, true
+		}
+		return evalSliceExpression(node, left, env), false
+	}
+	return Eval(node, env), false
+}
+
+// evaluates the left side of a link like Eval does, giving its errors its span
+func evalChainLink(node ast.Expression, env *object.Environment) (object.Object, bool) {
+	result, skipped := evalChain(node, env)
+	setErrorSpan(result, node)
+	return result, skipped
+}
+
+// struct members are its fields, instances have fields and methods. Dictionary members are looked up by the property name and
+// take priority over methods, so {"keys": 1}.keys is 1. Other members are the built-in methods of the value
+func evalMember(obj object.Object, name string) object.Object {
+	switch obj := obj.(type) {
+	case *object.Struct:
+		if value, ok := obj.Fields[name]; ok {
+			return value
+		}
+		return unknownFieldError(obj.Definition, name)
+	case *object.Instance:
+		return evalInstanceMember(obj, name)
+	}
+	hash, isHash := obj.(*object.Hash)
+	if isHash {
+		if value, ok := hash.Get(&object.String{Value: name}); ok {
+			return value
+		}
+	}
+	if method := lookUpMethod(obj, name); method != nil {
+		return method
+	}
+	if isHash {
+		return NULL
+	}
+	return newError(diagnostic.InvalidMember, "member access not supported: %s.%s", obj.Type(), name)
+}
+
+func evalSliceExpression(node *ast.SliceExpression, left object.Object, env *object.Environment) object.Object {
+	var length int64
+	switch left := left.(type) {
+	case *object.Array:
+		length = int64(len(left.Elements))
+	case *object.String:
+		length = int64(len([]rune(left.Value)))
+	default:
+		return newError(diagnostic.InvalidIndex, "slice operator not supported: %s", left.Type())
+	}
+
+	start, err := evalSliceBound(node.Start, env, 0, length)
+	if err != nil {
+		return err
+	}
+	end, err := evalSliceBound(node.End, env, length, length)
+	if err != nil {
+		return err
+	}
+	if start > end {
+		start = end
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		elements := make([]object.Object, end-start)
+		copy(elements, left.Elements[start:end])
+		return &object.Array{Elements: elements}
+	default:
+		return &object.String{Value: string([]rune(left.(*object.String).Value)[start:end])}
+	}
+}
+
+// a missing bound takes the default value, negative bounds count from the end
+// and every bound is clamped to the length of the sliced value
+func evalSliceBound(bound ast.Expression, env *object.Environment, defaultValue, length int64) (int64, object.Object) {
+	if bound == nil {
+		return defaultValue, nil
+	}
+	evaluated := Eval(bound, env)
+	if isError(evaluated) || isSignal(evaluated) {
+		return 0, evaluated
+	}
+	if bigInteger, ok := evaluated.(*object.BigInteger); ok {
+		if bigInteger.Value.Sign() < 0 {
+			return 0, nil
+		}
+		return length, nil
+	}
+	integer, ok := evaluated.(*object.Integer)
+	if !ok {
+		return 0, newError(diagnostic.InvalidIndex, "slice bound must be INTEGER, got %s", evaluated.Type())
+	}
+	value := integer.Value
+	if value < 0 {
+		value += length
+	}
+	return max(0, min(value, length)), nil
+}
+
+func formatFloat(value float64) string {
+	return (&object.Float{Value: value}).Inspect()
+}
+
+func nativeBoolToBooleanObject(value bool) *object.Boolean {
+	if value {
+		return TRUE
+	}
+	return FALSE
+}
+
+// truthiness used by conditions and the ! operator: only null and false are falsy,
+// every other value is truthy, including 0, 0.0, "" and empty arrays or dictionaries
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL, FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInteger:
+		return bigToFloat(obj.Value)
+	case *object.Float:
+		return obj.Value
+	}
+	return 0
+}
+
+func bigToFloat(value *big.Int) float64 {
+	result, _ := new(big.Float).SetInt(value).Float64()
+	return result
+}
+
+func toBigInt(obj object.Object) *big.Int {
+	if integer, ok := obj.(*object.Integer); ok {
+		return big.NewInt(integer.Value)
+	}
+	return obj.(*object.BigInteger).Value
+}
+
+// keeps integers in the int64 form whenever they fit, so equal values always share a representation
+func newInteger(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	return &object.BigInteger{Value: value}
+}
+
+func newError(code diagnostic.Code, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Code: code}
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
+
+// break, continue and return coming out of a block used as a value, like the branches of an if
+// expression. Like errors they stop the expression and are passed up to the enclosing loop or function
+func isSignal(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Break, *object.Continue, *object.ReturnValue:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,1330 @@
+package evaluator
+
+import (
+	"af/src/diagnostic"
+	"af/src/lexer"
+	"af/src/object"
+	"af/src/parser"
+	"testing"
+)
+
+func testEval(input string) object.Object {
+	l := lexer.NewLexer(input)
+	p := parser.NewParser(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+	return Eval(program, env)
+}
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"10 % 3", 1},
+		{"-10 % 3", -1},
+		{"10 % -3", 1},
+		{"2 + 10 % 4 * 3", 8},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"5.5", 5.5},
+		{"-10.25", -10.25},
+		{"1.5 + 1.5", 3.0},
+		{"2 * 1.5", 3.0},
+		{"1.0 / 4", 0.25},
+		{"10 - 0.5", 9.5},
+		{"5.5 % 2", 1.5},
+		{"-5.5 % 2", -1.5},
+		{"7 % 2.5", 2.0},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"1.5 > 1", true},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 1", true},
+		{"1 >= 2", false},
+		{"1.5 >= 1.5", true},
+		{"1 <= 0.5", false},
+		{"1.0 == 1", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"(1 > 2) == true", false},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBangOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"Hello World!"`, "Hello World!"},
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`"tab\there"`, "tab\there"},
+		{`let name = "Ander"; "Hello ${name}!"`, "Hello Ander!"},
+		{`let age = 24; "${age + 1} years, ${1.5 * 2}, ${age > 18}"`, "25 years, 3.0, true"},
+		{`"nested ${"in${"ner"}"}"`, "nested inner"},
+		{`"\${not} interpolated"`, "${not} interpolated"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. got=%q, want=%q", str.Value, tt.expected)
+		}
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+	}
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-4]", nil},
+		{`"añb"[1]`, "ñ"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("object is not String %q. got=%T (%+v)", expected, evaluated, evaluated)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:-1]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4][1:100]", "[2, 3, 4]"},
+		{"[1, 2, 3, 4][3:1]", "[]"},
+		{`["a", "b"][0:1]`, `["a"]`},
+		{`"hello"[1:3]`, "el"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong slice result. got=%q, want=%q", evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+
+	inspected := `{"one": 1, "two": 2, "three": 3, 4: 4, true: 5, false: 6}`
+	if result.Inspect() != inspected {
+		t.Errorf("wrong Inspect. got=%q, want=%q", result.Inspect(), inspected)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+		{`let d = {"name": "Ander", "age": 24}; d["age"]`, 24},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"let x = if (false) { 1 } else { 2 }; x", 2},
+		{"let n = 5; if (n < 0) { 1 } else if (n < 10) { 2 } else { 3 }", 2},
+		{"let n = 50; if (n < 0) { 1 } else if (n < 10) { 2 } else { 3 }", 3},
+		{"let n = 50; if (n < 0) { 1 } else if (n < 10) { 2 }", nil},
+		{"if (true) { }", nil},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestTruthiness(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"if (false) { 1 }", false},
+		{"0", true},
+		{"0.0", true},
+		{`""`, true},
+		{"[]", true},
+		{"{}", true},
+		{"fn() { 1 }", true},
+	}
+	for _, tt := range tests {
+		condition := "if (" + tt.input + ") { true } else { false }"
+		testBooleanObject(t, testEval(condition), tt.expected)
+	}
+}
+
+func TestLogicalExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 2", true},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 < 3", true},
+		{"true || false && false", true},
+		{"(true || false) && false", false},
+	}
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestLogicalShortCircuit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"false && missing", false},
+		{"true || missing", true},
+		{"let d = {}; d[\"x\"] && d[\"x\"][\"ok\"]", false},
+		{"fn fail() { return true + false } false && fail()", false},
+	}
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestWhileStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"while (false) { 1 }", nil},
+		{"while (true) { break }", nil},
+		{"fn f() { while (true) { return 5 } } f()", 5},
+		{"fn f() { while (true) { if (true) { break } } return 1 } f()", 1},
+		{"let x = 1; while (x > 5) { x } x", 1},
+		{"let i = 0; let sum = 0; while (i < 3) { const c = i; sum += c; i += 1 } sum", 3},
+		{"let i = 0; let sum = 0; while (i < 3) { const [a] = [i]; sum += a; i += 1 } sum", 3},
+		{"let i = 0; let fs = []; while (i < 3) { let j = i; fs.push(fn() { j }); i += 1 } fs[1]()", 1},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestLoopSignalsInExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let i = 0; while (i < 5) { i += 1; let x = if (i == 3) { break } else { 1 }; } i", 3},
+		{"let s = 0; for (x in [1, 2, 3]) { s = s + if (x == 2) { continue } else { x } } s", 4},
+		{"let s = 0; for (x in [1, 2, 3]) { s += if (x == 2) { continue } else { x } } s", 4},
+		{"let s = 0; for (x in [1, 2, 3]) { s = if (x == 3) { break } else { x } + s } s", 3},
+		{"let n = 0; for (x in [1, 2, 3]) { let a = [x, if (x == 2) { continue } else { x }]; n += 1 } n", 2},
+		{"let n = 0; for (x in [1, 2, 3]) { f(if (x == 2) { break } else { x }); n += 1 } n", 1},
+		{"let n = 0; for (x in [1, 2, 3]) { let d = {\"k\": if (x == 1) { continue } else { x }}; n += d.k } n", 5},
+		{"let n = 0; for (x in [1, 2, 3]) { if (x > 1 && if (x == 2) { continue } else { true }) { n += x } } n", 3},
+		{"let n = 0; for (x in [1, 2, 3]) { n += [10, 20, 30][if (x == 2) { continue } else { x - 1 }] } n", 40},
+		{"let n = 0; for (x in [1, 2, 3]) { n += -if (x == 2) { continue } else { x } } n", -4},
+		{"let n = 0; for (x in [1, 2, 3]) { n += match (x) { 2 => if (true) { continue }, _ => x } } n", 4},
+	}
+	for _, tt := range tests {
+		evaluated := testEval("fn f(x) { x }\n" + tt.input)
+		if !testIntegerObject(t, evaluated, tt.expected) {
+			t.Errorf("for input %q", tt.input)
+		}
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fn f() { for (x in [1, 2, 3]) { if (x > 1) { return x } } } f()`, "2"},
+		{`fn f() { for (i, x in ["a", "b"]) { if (x == "b") { return i } } } f()`, "1"},
+		{`fn f() { for (k in {"a": 1, "b": 2}) { if (k != "a") { return k } } } f()`, "b"},
+		{`fn f() { for (k, v in {"a": 1, "b": 2}) { if (k == "b") { return v } } } f()`, "2"},
+		{`fn f() { for (c in "añ") { if (c != "a") { return c } } } f()`, "ñ"},
+		{`fn f() { for (x in [1, 2, 3]) { if (x < 3) { continue } return x } } f()`, "3"},
+		{`fn f() { for (x in [1, 2, 3]) { break; return x } return 0 } f()`, "0"},
+		{`for (x in []) { x }`, "null"},
+		{`let x = 10; for (x in [1]) { x }; x`, "10"},
+		{`
+		fn f() {
+			for (x in [1, 2]) {
+				for (y in [1, 2, 3]) {
+					if (y == 2) { break }
+					if (x == 2) { return x * 10 + y }
+				}
+			}
+		}
+		f()`, "21"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestLoopClosuresCaptureIteration(t *testing.T) {
+	input := `
+	fn collect() {
+		for (x in [1, 2, 3]) {
+			if (x == 2) { return fn() { x } }
+		}
+	}
+	collect()()`
+
+	testIntegerObject(t, testEval(input), 2)
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1; x = 2; x", "2"},
+		{"let x = 1; x = x + 1", "2"},
+		{"let x = 10; x += 5; x", "15"},
+		{"let x = 10; x -= 5; x", "5"},
+		{"let x = 10; x *= 5; x", "50"},
+		{"let x = 10; x /= 5; x", "2"},
+		{"let x = 10; x %= 4; x", "2"},
+		{"let x = 10; x /= 4.0; x", "2.5"},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let a = 1; let b = 2; a = b = 3; a + b", "6"},
+		{"let arr = [1, 2, 3]; arr[0] = 10; arr", "[10, 2, 3]"},
+		{"let arr = [1, 2, 3]; arr[-1] += 10; arr", "[1, 2, 13]"},
+		{`let d = {"a": 1}; d["b"] = 2; d["a"] += 5; d`, `{"a": 6, "b": 2}`},
+		{`let d = {"list": [1]}; d["list"][0] = 5; d`, `{"list": [5]}`},
+		{"let count = 0; fn inc() { count += 1 } inc(); inc(); count", "2"},
+		{"let i = 0; let sum = 0; while (i < 5) { i += 1; sum += i } sum", "15"},
+		{"let i = 0; while (true) { i += 1; if (i == 3) { break } } i", "3"},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x % 2 == 0) { continue } sum += x } sum", "4"},
+		{"let arr = [1, 2]; let other = arr; other[0] = 9; arr", "[9, 2]"},
+		{"let a = [1]; a[0] = a; a", "[[...]]"},
+		{"let a = [1]; a.push(a); [a, a]", "[[1, [...]], [1, [...]]]"},
+		{`let d = {"a": 1}; d["self"] = d; d`, `{"a": 1, "self": {...}}`},
+		{`let d = {}; let a = [d]; d["list"] = a; a`, `[{"list": [...]}]`},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const x = 5; x", "5"},
+		{"const x = 5; fn f() { let x = 1; x += 1; x } f()", "2"},
+		{"const d = {\"a\": 1}; d[\"a\"] = 2; d", `{"a": 2}`},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestNullExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"null", "null"},
+		{"null == null", "true"},
+		{"null != 1", "true"},
+		{"!null", "true"},
+		{"let x = null; x", "null"},
+		{"fn f() { } f()", "null"},
+		{`{"a": 1}["b"]`, "null"},
+		{"null ?? 5", "5"},
+		{"1 ?? 5", "1"},
+		{"false ?? 5", "false"},
+		{"0 ?? 5", "0"},
+		{"null ?? null ?? 3", "3"},
+		{"1 ?? missing", "1"},
+		{`let config = {"db": {"host": "localhost"}}; config?.db?.host`, "localhost"},
+		{`let config = {"db": {"host": "localhost"}}; config?.cache?.host`, "null"},
+		{`let config = {"db": {"host": "localhost"}}; config?.cache?.host ?? "none"`, "none"},
+		{`let config = null; config?.db`, "null"},
+		{`let items = null; items?.[0]`, "null"},
+		{`let items = [1, 2]; items?.[1]`, "2"},
+		{`let items = null; items?.[1:]`, "null"},
+		{`let items = null; items?.[missing]`, "null"},
+		{`let d = {"list": [7]}; d?.list?.[0]`, "7"},
+		{`let s = null; s?.upper()`, "null"},
+		{`let s = "ab"; s?.upper()`, "AB"},
+		{`let c = null; c?.db.host`, "null"},
+		{`let c = null; c?.db.host.port ?? 80`, "80"},
+		{`let c = {"db": {"host": "h"}}; c?.db.host`, "h"},
+		{`let x = null; x?.[0][1]`, "null"},
+		{`let x = [[1, 2]]; x?.[0][1]`, "2"},
+		{`let x = null; x?.[0][1:]`, "null"},
+		{`let x = null; x?.list.len() + 1`, "ERROR: type mismatch: NULL + INTEGER"},
+		{`let c = null; c?.f(missing)`, "null"},
+		{`let c = {"db": null}; c.db?.host.port`, "null"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+// the lines of a REPL session are parsed separately but share the environment
+func TestConstantsAcrossLines(t *testing.T) {
+	tests := []struct {
+		lines    []string
+		expected string
+	}{
+		{[]string{"const x = 1", "let x = 2"}, "cannot redeclare constant x"},
+		{[]string{"const x = 1", "const x = 2"}, "cannot redeclare constant x"},
+		{[]string{"const x = 1", "let [x] = [2]"}, "cannot redeclare constant x"},
+		{[]string{"const f = 1", "fn f() { 2 }"}, "cannot redeclare constant f"},
+		{[]string{"const P = 1", "struct P { x }"}, "cannot redeclare constant P"},
+		{[]string{"const A = 1", "class A {}"}, "cannot redeclare constant A"},
+		{[]string{"const x = 1", "x = 2"}, "cannot assign to constant x"},
+		{[]string{"const x = 1", "fn f() { let x = 2; x } f()"}, "2"},
+		{[]string{"let x = 1", "let x = 2", "x = 3; x"}, "3"},
+	}
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		var evaluated object.Object
+		for _, line := range tt.lines {
+			evaluated = Eval(parser.NewParser(lexer.NewLexer(line)).ParseProgram(), env)
+		}
+		result := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			result = errObj.Message
+		}
+		if result != tt.expected {
+			t.Errorf("wrong result for %q. got=%q, want=%q", tt.lines, result, tt.expected)
+		}
+	}
+
+	env := object.NewEnvironment()
+	Eval(parser.NewParser(lexer.NewLexer("const x = 1")).ParseProgram(), env)
+	Eval(parser.NewParser(lexer.NewLexer("let x = 2")).ParseProgram(), env)
+	x, _ := env.Get("x")
+	testIntegerObject(t, x, 1)
+	if !env.IsConstant("x") {
+		t.Errorf("x is no longer a constant after the failed let")
+	}
+}
+
+func TestMethods(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"héllo".len()`, "5"},
+		{`"Hello".upper()`, "HELLO"},
+		{`"Hello".lower()`, "hello"},
+		{`"  hi \t".trim()`, "hi"},
+		{`"a,b,c".split(",")`, `["a", "b", "c"]`},
+		{`"hello".contains("ell")`, "true"},
+		{`"hello".startsWith("he") && "hello".endsWith("lo")`, "true"},
+		{`"a-b-c".replace("-", "+")`, "a+b+c"},
+		{`let s = "Hi"; s.lower().upper()`, "HI"},
+		{`let f = "abc".upper; f()`, "ABC"},
+		{`[1, 2, 3].len()`, "3"},
+		{`let arr = [1]; arr.push(2); arr.push("x"); arr`, `[1, 2, "x"]`},
+		{`let arr = [1, 2]; arr.pop() + arr.len()`, "3"},
+		{`[].pop()`, "null"},
+		{`[1, "a", 2.0].contains("a") && [1, 2].contains(2.0)`, "true"},
+		{`[1, 2, 3].indexOf(3)`, "2"},
+		{`[1, 2].indexOf(5)`, "-1"},
+		{`[1, "a", true].join("-")`, "1-a-true"},
+		{`let arr = [1, 2, 3]; [arr.reverse(), arr]`, "[[3, 2, 1], [1, 2, 3]]"},
+		{`[1, 2, 3].map(fn(x) { x * 2 })`, "[2, 4, 6]"},
+		{`[1, 2, 3, 4].filter(fn(x) { x % 2 == 0 })`, "[2, 4]"},
+		{`{"a": 1, "b": 2}.len()`, "2"},
+		{`{"a": 1, "b": 2}.keys()`, `["a", "b"]`},
+		{`{"a": 1, "b": 2}.values()`, "[1, 2]"},
+		{`{"a": 1}.contains("a")`, "true"},
+		{`{"a": 1}.get("b", 0) + {"a": 1}.get("a", 0)`, "1"},
+		{`let d = {"a": 1, "b": 2}; d.remove("a"); d`, `{"b": 2}`},
+		{`{"a": 1}.remove("b")`, "false"},
+		{`{"keys": 1}.keys`, "1"},
+		{`{"a": 1}.missing`, "null"},
+		{`let d = {"a": 1}; d.keys`, "builtin HASH.keys"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestStructs(t *testing.T) {
+	point := "struct Point { x, y }\n"
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{point + "Point", "struct Point { x, y }"},
+		{point + `Point{y: "b", x: 1}`, `Point{x: 1, y: "b"}`},
+		{point + "let p = Point{x: 1, y: 2}; p.x + p.y", "3"},
+		{point + "let p = Point{x: 1, y: 2}; p.x = 10; p", "Point{x: 10, y: 2}"},
+		{point + "let p = Point{x: 1, y: 2}; p.y *= 3; p.y", "6"},
+		{point + "let p = Point{x: 1, y: 2}; let q = p; q.x = 5; p.x", "5"},
+		{point + "Point{x: 1, y: 2} == Point{x: 1, y: 2}", "true"},
+		{point + "Point{x: 1, y: 2} == Point{x: 1.0, y: 2}", "true"},
+		{point + "Point{x: 1, y: 2} != Point{x: 1, y: 3}", "true"},
+		{point + "struct Pair { x, y }\nPoint{x: 1, y: 2} == Pair{x: 1, y: 2}", "false"},
+		{point + "struct Line { from, to }\nLine{from: Point{x: 0, y: 0}, to: Point{x: 1, y: 1}} == Line{from: Point{x: 0, y: 0}, to: Point{x: 1, y: 1}}", "true"},
+		{point + "Point{x: 1, y: 2} == 1", "false"},
+		{point + "Point{x: [1, 2], y: 2} == Point{x: [1, 2], y: 2}", "true"},
+		{point + "Point{x: [1, 2], y: 2} == Point{x: [1, 3], y: 2}", "false"},
+		{point + "Point{x: [1, 2], y: 2} == Point{x: [1], y: 2}", "false"},
+		{point + "Point{x: [[1], Point{x: 0, y: 0}], y: 2} == Point{x: [[1.0], Point{x: 0, y: 0}], y: 2}", "true"},
+		{point + `Point{x: {"a": 1, "b": [2]}, y: 2} == Point{x: {"b": [2], "a": 1}, y: 2}`, "true"},
+		{point + `Point{x: {"a": 1}, y: 2} == Point{x: {"a": 2}, y: 2}`, "false"},
+		{point + `Point{x: {"a": 1}, y: 2} == Point{x: {"b": 1}, y: 2}`, "false"},
+		{point + `Point{x: {"a": 1}, y: 2} == Point{x: {"a": 1, "b": 1}, y: 2}`, "false"},
+		{point + `Point{x: {"a": 1}, y: 2} == Point{x: [1], y: 2}`, "false"},
+		{point + "Point{x: [1], y: 2} != Point{x: [1], y: 2}", "false"},
+		{point + "[Point{x: 1, y: 2}].contains(Point{x: 1, y: 2})", "true"},
+		{point + "let p = Point{x: 1, y: 2}; p.x = p; p", "Point{x: {...}, y: 2}"},
+		{point + "let a = [1]; a[0] = a; let b = [1]; b[0] = b; Point{x: a, y: 2} == Point{x: b, y: 2}", "true"},
+		{point + "let a = [1]; a.push(a); let b = [1]; b.push(b); Point{x: a, y: 2} == Point{x: b, y: 2}", "true"},
+		{point + "let a = [1]; a.push(a); let b = [2]; b.push(b); Point{x: a, y: 2} == Point{x: b, y: 2}", "false"},
+		{point + "let p = Point{x: 1, y: 2}; p.x = p; let q = Point{x: 1, y: 2}; q.x = q; p == q", "true"},
+		{"struct Empty {}\nEmpty{}", "Empty{}"},
+		{`let d = {}; d.name = "Ander"; d`, `{"name": "Ander"}`},
+		{`let d = {"count": 1}; d.count += 1; d.count`, "2"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestClasses(t *testing.T) {
+	shapes := `
+class Shape {
+	fn init(name) { self.name = name }
+	fn area() { 0 }
+	fn describe() { self.name + " with area " + str(self.area()) }
+}
+class Rect extends Shape {
+	fn init(w, h) {
+		super.init("rect")
+		self.w = w
+		self.h = h
+	}
+	fn area() { self.w * self.h }
+}
+class Square extends Rect {
+	fn init(side) { super.init(side, side); self.name = "square" }
+	fn describe() { "a " + super.describe() }
+}
+fn str(x) { "${x}" }
+`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{shapes + "Shape", "class Shape"},
+		{shapes + "Square", "class Square extends Rect"},
+		{shapes + "Rect(2, 3)", `Rect{name: "rect", w: 2, h: 3}`},
+		{shapes + "Rect(2, 3).area()", "6"},
+		{shapes + "Shape(\"blob\").describe()", "blob with area 0"},
+		{shapes + "Rect(2, 3).describe()", "rect with area 6"},
+		{shapes + "Square(3).describe()", "a square with area 9"},
+		{shapes + "let s = Square(2); s.w = 5; s.area()", "10"},
+		{shapes + "let s = Square(2); let area = s.area; s.h = 4; area()", "8"},
+		{shapes + "Square(2).area", "method Rect.area"},
+		{shapes + "let r = Rect(1, 1); r == r", "true"},
+		{shapes + "Rect(1, 1) == Rect(1, 1)", "false"},
+		{"class Empty {} Empty()", "Empty{}"},
+		{"class Empty {} let e = Empty(); e.tag = 1; e.tag += 1; e", "Empty{tag: 2}"},
+		{"class Empty {} let e = Empty(); e.me = e; [e]", "[Empty{me: {...}}]"},
+		{`
+class Counter {
+	fn init() { self.count = 0 }
+	fn increment() { self.count += 1; self }
+}
+let c = Counter();
+c.increment().increment().count`, "2"},
+		{`
+class Greeter {
+	fn init(greeting) { self.greeting = greeting }
+	fn greeter() { fn(name) { self.greeting + " " + name } }
+}
+Greeter("hi").greeter()("Ander")`, "hi Ander"},
+		{`
+class A { fn who() { "A" } fn call() { self.who() } }
+class B extends A { fn who() { "B" } }
+class C extends B { fn who() { "C" + super.who() } }
+[A().call(), B().call(), C().call()]`, `["A", "B", "CB"]`},
+		{`class A { fn init() { self.x = 1; return 5 } } A().x`, "1"},
+		{"fn f() { class A {} } f()", "null"},
+		{"fn f() { class A {} } [f(), f() == 1]", "[null, false]"},
+		{"let x = if (true) { class A {} }; x", "null"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match (1) { 0 => "zero", 1 => "one", _ => "many" }`, `one`},
+		{`match (7) { 0 => "zero", 1 => "one", _ => "many" }`, `many`},
+		{`match (-2) { -2 => "minus two", _ => "other" }`, `minus two`},
+		{`match (1.5) { 1.5 => "yes", _ => "no" }`, `yes`},
+		{`match ("b") { "a" => 1, "b" => 2 }`, `2`},
+		{`match (null) { null => "nothing", _ => "something" }`, `nothing`},
+		{`match (false) { true => 1, false => 2 }`, `2`},
+		{`match (3) { 1 => "one" }`, `null`},
+		{`match (5) { n => n * 2 }`, `10`},
+		{`match (5) { n if n > 10 => "big", n if n > 0 => "positive", _ => "other" }`, `positive`},
+		{`match (-5) { n if n > 10 => "big", n if n > 0 => "positive", _ => "other" }`, `other`},
+		{`let n = 1; match (5) { n => n }; n`, `1`},
+		{`match ([1, 2, 3]) { [] => 0, [a] => a, [a, b] => a + b, [a, b, c] => a + b + c }`, `6`},
+		{`match ([]) { [] => "empty", _ => "other" }`, `empty`},
+		{`match ([1, 2, 3, 4]) { [first, ...rest] => rest }`, `[2, 3, 4]`},
+		{`match ([1, 2, 3, 4]) { [...init, last] => [init, last] }`, `[[1, 2, 3], 4]`},
+		{`match ([1, 2, 3, 4]) { [a, ...mid, b] => mid }`, `[2, 3]`},
+		{`match ([1, 2]) { [a, ...mid, b] => mid }`, `[]`},
+		{`match ([1]) { [a, ...mid, b] => "two or more", _ => "fewer" }`, `fewer`},
+		{`match ([1, [2, 3]]) { [1, [x, 3]] => x }`, `2`},
+		{`match ([1, 2]) { [2, x] => x, [1, x] => -x }`, `-2`},
+		{`match ("ab") { [a, b] => a, _ => "not an array" }`, `not an array`},
+		{`match ({"name": "Ann", "age": 30}) { {name, age: 30} => name }`, `Ann`},
+		{`match ({"name": "Ann", "age": 31}) { {age: 30} => "thirty", {age} => age }`, `31`},
+		{`match ({"name": "Ann"}) { {age} => age, _ => "no age" }`, `no age`},
+		{`match ({1: "one"}) { {1: x} => x }`, `one`},
+		{`match ({"tags": ["a", "b"]}) { {tags: [first, ..._]} => first }`, `a`},
+		{`match ({}) { {} => "any dict" }`, `any dict`},
+		{`match ([]) { {} => "dict", _ => "not a dict" }`, `not a dict`},
+		{`struct Point { x, y } match (Point{x: 0, y: 2}) { {x: 0, y} => y }`, `2`},
+		{`struct Point { x, y } match (Point{x: 1, y: 2}) { {z} => z, _ => "no z" }`, `no z`},
+		{`class A { fn init() { self.x = 4 } } match (A()) { {x} => x }`, `4`},
+		{`let x = 5; match (x) { 5 => "five", _ => "other" }`, `five`},
+		{`match (2) { 1 => "a", 2 => match ("x") { "x" => "bx", _ => "b" }, _ => "c" }`, `bx`},
+		{`fn f(v) { match (v) { [x, ..._] => x, _ => null } } f([9, 8])`, `9`},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b, ...rest] = [1, 2, 3, 4]; [a, b, rest]", "[1, 2, [3, 4]]"},
+		{"let [a, ...rest] = [1]; rest", "[]"},
+		{"let [...init, last] = [1, 2, 3]; [init, last]", "[[1, 2], 3]"},
+		{"let [_, second] = [1, 2]; second", "2"},
+		{`let {name, age: years} = {"name": "Ann", "age": 30}; [name, years]`, `["Ann", 30]`},
+		{`let {"first name": first} = {"first name": "Ann"}; first`, "Ann"},
+		{`let {1: one} = {1: "uno"}; one`, "uno"},
+		{`let [id, {tags: [tag, ..._]}] = [7, {"tags": ["a", "b"]}]; [id, tag]`, `[7, "a"]`},
+		{"struct Point { x, y } let {x, y} = Point{x: 1, y: 2}; x + y", "3"},
+		{"class A { fn init() { self.v = 5 } } let {v} = A(); v", "5"},
+		{"let [1, x] = [1, 2]; x", "2"},
+		{"const [a, b] = [1, 2]; a + b", "3"},
+		{"let a = 0; fn f() { let [a] = [5]; a } [f(), a]", "[5, 0]"},
+		{"fn swap([a, b]) { [b, a] } swap([1, 2])", "[2, 1]"},
+		{`fn greet({name}, greeting) { greeting + " " + name } greet({"name": "Ann"}, "hi")`, "hi Ann"},
+		{"let sum = fn([first, ...rest]) { if (rest.len() == 0) { first } else { first + sum(rest) } }; sum([1, 2, 3])", "6"},
+		{`[[1, 2], [3, 4]].map(fn([a, b]) { a * b })`, "[2, 12]"},
+		{`class P { fn init({x, y}) { self.x = x; self.y = y } } P({"x": 1, "y": 2})`, "P{x: 1, y: 2}"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestDestructuringMismatch(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+		expectedSpan    string
+	}{
+		{"let [a, b] = [1, 2, 3]", "expected 2 elements at value, got 3", "1:5-1:11"},
+		{"let [a, b, ...c] = [1]", "expected at least 2 elements at value, got 1", "1:5-1:17"},
+		{"let [a, b] = 5", "expected an array at value, got INTEGER", "1:5-1:11"},
+		{"let {a} = [1]", "expected a dictionary at value, got ARRAY", "1:5-1:8"},
+		{`let {name, age} = {"name": "Ann"}`, `missing "age" at value`, "1:12-1:15"},
+		{`let [a, {b: [c, d]}] = [1, {"b": [2]}]`, `expected 2 elements at value[1]["b"], got 1`, "1:13-1:19"},
+		{`let [a, {b: {c}}] = [1, {"b": {"d": 2}}]`, `missing "c" at value[1]["b"]`, "1:14-1:15"},
+		{`let [..._, {x}] = [1, 2, 3]`, "expected a dictionary at value[2], got INTEGER", "1:12-1:15"},
+		{"let [1, x] = [2, 3]", "expected 1 at value[0], got 2", "1:6-1:7"},
+		{`let ["1"] = [1]`, `expected "1" at value[0], got 1`, "1:6-1:9"},
+		{`let [1] = ["1"]`, `expected 1 at value[0], got "1"`, "1:6-1:7"},
+		{"struct Point { x, y } let {z} = Point{x: 1, y: 2}", `missing "z" at value`, "1:28-1:29"},
+		{"struct Inner { v } struct Outer { inner } let {inner: {v: [a]}} = Outer{inner: Inner{v: 1}}", "expected an array at value.inner.v, got INTEGER", "1:59-1:62"},
+		{"fn f([a, b]) { a } f([1])", "expected 2 elements at argument 1, got 1", "1:6-1:12"},
+		{`fn f(x, {y}) { y } f(1, {"z": 2})`, `missing "y" at argument 2`, "1:10-1:11"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, tt.expectedMessage, errObj.Message)
+		}
+		d := errObj.Diagnostic()
+		if d.Code != diagnostic.PatternMismatch {
+			t.Errorf("%q: wrong code. expected=%s, got=%s", tt.input, diagnostic.PatternMismatch, d.Code)
+		}
+		if d.Span.String() != tt.expectedSpan {
+			t.Errorf("%q: wrong span. expected=%s, got=%s", tt.input, tt.expectedSpan, d.Span)
+		}
+	}
+}
+
+func TestFailedDestructuringBindsNothing(t *testing.T) {
+	env := object.NewEnvironment()
+	program := parser.NewParser(lexer.NewLexer("let a = 0; let [a, b] = [1]")).ParseProgram()
+	if result := Eval(program, env); !isError(result) {
+		t.Fatalf("expected an error, got=%s", result.Inspect())
+	}
+	a, _ := env.Get("a")
+	testIntegerObject(t, a, 0)
+	if _, ok := env.Get("b"); ok {
+		t.Errorf("b was bound by the failed let")
+	}
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	input := `
+	fn fib(n) {
+		if (n < 2) { return n }
+		return fib(n - 1) + fib(n - 2)
+	}
+	fib(10)`
+
+	testIntegerObject(t, testEval(input), 55)
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{"return 10", 10},
+		{
+			`if (10 > 1) {
+				if (10 > 1) {
+					return 10;
+				}
+				return 1;
+			}`,
+			10,
+		},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestReturnFromIfExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"fn f() { let x = if (true) { return 1 } else { 2 }; 3 } f()", 1},
+		{"fn f() { let x = if (false) { return 1 } else { 2 }; x + 1 } f()", 3},
+		{"fn f() { let x = 0; x = if (true) { return 1 } else { 2 }; 3 } f()", 1},
+		{"fn f() { 10 + if (true) { return 1 } else { 2 } } f()", 1},
+		{"fn f() { [if (true) { return 1 } else { 2 }, 5] } f()", 1},
+		{"fn f(x) { g(if (x > 0) { return x } else { 0 }); 9 } fn g(y) { y } f(4)", 4},
+		{"fn f() { for (x in [1, 2]) { let y = if (x == 2) { return x } else { x } } 0 } f()", 2},
+		{"fn f() { let s = 0; while (true) { s += if (s > 2) { return s } else { 1 } } } f()", 3},
+		{"let x = if (true) { return 7 } else { 2 }; 3", 7},
+	}
+	for _, tt := range tests {
+		if !testIntegerObject(t, testEval(tt.input), tt.expected) {
+			t.Errorf("for input %q", tt.input)
+		}
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let a = 5\nlet b = a * 2\nb", 10},
+		{"let value1 = 2; let value2 = value1 * 3; value2", 6},
+		{"let año = 2024; let días = 365; año + días", 2389},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "fn(x) { x + 2; };"
+
+	evaluated := testEval(input)
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("function has wrong parameters. Parameters=%+v", fn.Parameters)
+	}
+	if fn.Parameters[0].PrintAsString() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
+	}
+	expectedBody := "{ (x + 2) }"
+	if fn.Body.PrintAsString() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.PrintAsString())
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"fn add(x, y) { return x + y } add(2, 3)", 5},
+		{"fn quad(n) { return double(n) * 2 } fn double(n) { return n * 2 } quad(4)", 16},
+		{"let early = fn() { return 1; 2 }; early() + 10", 11},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+	let newAdder = fn(x) {
+		fn(y) { x + y };
+	};
+	let addTwo = newAdder(2);
+	addTwo(2);`
+
+	testIntegerObject(t, testEval(input), 4)
+}
+
+func TestClosuresCaptureDefinitionScope(t *testing.T) {
+	input := `
+	let x = 10;
+	fn getX() { x }
+	fn shadow(x) { getX() }
+	shadow(99);`
+
+	testIntegerObject(t, testEval(input), 10)
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"foobar", "identifier not found: foobar"},
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{`"a ${missing}"`, "identifier not found: missing"},
+		{`[1, 2]["a"]`, "index operator not supported: ARRAY[STRING]"},
+		{`5[0]`, "index operator not supported: INTEGER[INTEGER]"},
+		{`[1, 2][true:]`, "slice bound must be INTEGER, got BOOLEAN"},
+		{`[1, foo]`, "identifier not found: foo"},
+		{`{"name": "Ander"}[[1]];`, "unusable as hash key: ARRAY"},
+		{`{[1]: "Ander"}`, "unusable as hash key: ARRAY"},
+		{`{1.5: "Ander"}`, "unusable as hash key: FLOAT"},
+		{`{fn(x) { x }: "Ander"}`, "unusable as hash key: FUNCTION"},
+		{`let f = fn(x) { x }; {"a": 1}[f]`, "unusable as hash key: FUNCTION"},
+		{`let a = 5; a(1)`, "not a function: INTEGER"},
+		{`fn add(x, y) { x + y } add(1)`, "wrong number of arguments: want=2, got=1"},
+		{`fn f() { return true + false; 10 } f()`, "unknown operator: BOOLEAN + BOOLEAN"},
+		{"let a = b; 5", "identifier not found: b"},
+		{"return -true; 5", "unknown operator: -BOOLEAN"},
+		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (missing) { 1 }", "identifier not found: missing"},
+		{"true && missing", "identifier not found: missing"},
+		{"missing || true", "identifier not found: missing"},
+		{`"a" <= "b"`, "unknown operator: STRING <= STRING"},
+		{"10 / 0", "division by zero: 10 / 0"},
+		{"2 ** 64 % 0", "division by zero: 18446744073709551616 % 0"},
+		{"let big = 2 ** 64; big / (big - big)", "division by zero: 18446744073709551616 / 0"},
+		{`"a" ** 2`, "type mismatch: STRING ** INTEGER"},
+		{"2 ** 1000000000000", "integer too large: 2 ** 1000000000000 has more than 1048576 bits"},
+		{"(2 ** 64) ** 100000", "integer too large: 18446744073709551616 ** 100000 has more than 1048576 bits"},
+		{"10 ** (2 ** 64)", "integer too large: 10 ** 18446744073709551616 has more than 1048576 bits"},
+		{"10 % 0", "division by zero: 10 % 0"},
+		{"1.5 / 0", "division by zero: 1.5 / 0"},
+		{"1.5 % 0.0", "division by zero: 1.5 % 0"},
+		{"let zero = 0; 10 / (zero * 2); 5", "division by zero: 10 / 0"},
+		{`"a" % "b"`, "unknown operator: STRING % STRING"},
+		{"for (x in 5) { x }", "cannot iterate over INTEGER"},
+		{"for (x in missing) { x }", "identifier not found: missing"},
+		{"while (missing) { 1 }", "identifier not found: missing"},
+		{"for (x in [1]) { x + true }", "type mismatch: INTEGER + BOOLEAN"},
+		{"x = 5", "assignment to undeclared identifier: x"},
+		{"x += 5", "identifier not found: x"},
+		{"fn f() { y = 1 } f()", "assignment to undeclared identifier: y"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let x = 1; x /= 0", "division by zero: 1 / 0"},
+		{"let arr = [1]; arr[5] = 1", "index out of range: 5 with length 1"},
+		{`let arr = [1]; arr["a"] = 1`, "index operator not supported: ARRAY[STRING]"},
+		{"let d = {}; d[[1]] = 1", "unusable as hash key: ARRAY"},
+		{`let s = "abc"; s[0] = "x"`, "index assignment not supported: STRING[INTEGER]"},
+		{"fn f() { limit = 10 } const limit = 5; f()", "cannot assign to constant limit"},
+		{"null + 1", "type mismatch: NULL + INTEGER"},
+		{"null[0]", "index operator not supported: NULL[INTEGER]"},
+		{"5?.name", "member access not supported: INTEGER.name"},
+		{`let c = {"db": null}; c?.db.host`, "member access not supported: NULL.host"},
+		{"null ?? missing", "identifier not found: missing"},
+		{`"abc".reverse()`, "member access not supported: STRING.reverse"},
+		{`"abc".upper(1)`, "wrong number of arguments to upper: want=0, got=1"},
+		{`"abc".split(1)`, "argument 1 to split must be STRING, got INTEGER"},
+		{`[1].map(5)`, "not a function: INTEGER"},
+		{`[1].filter(fn(x) { x + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`{}.get([1], 0)`, "unusable as hash key: ARRAY"},
+		{`missing.len()`, "identifier not found: missing"},
+		{"struct Point { x, y } Point{x: 1}", "missing field y in Point literal"},
+		{"struct Point { x, y } Point{x: 1, y: 2, z: 3}", "struct Point has no field z"},
+		{"struct Point { x, y } Point{x: 1, y: missing}", "identifier not found: missing"},
+		{"struct Point { x, y } Point{x: 1, y: 2}.z", "struct Point has no field z"},
+		{"struct Point { x, y } let p = Point{x: 1, y: 2}; p.z = 1", "struct Point has no field z"},
+		{"struct Point { x, y } let p = Point{x: 1, y: 2}; p.z += 1", "struct Point has no field z"},
+		{"struct Point { x, y } Point{x: 1, y: 2} + 1", "type mismatch: STRUCT + INTEGER"},
+		{"struct Point { x, y } Point{x: 1, y: 2} < Point{x: 1, y: 2}", "unknown operator: STRUCT < STRUCT"},
+		{"let Point = 5; Point{x: 1}", "not a struct: INTEGER"},
+		{"Point{x: 1}", "identifier not found: Point"},
+		{"let s = \"abc\"; s.size = 1", "member assignment not supported: STRING.size"},
+		{"class A {} A(1)", "wrong number of arguments: want=0, got=1"},
+		{"class A { fn init(x) { } } A()", "wrong number of arguments: want=1, got=0"},
+		{"class A { fn init() { missing } } A()", "identifier not found: missing"},
+		{"class A {} A().missing", "A has no field or method missing"},
+		{"class A {} class B extends A { fn f() { super.f() } } B().f()", "A has no method f"},
+		{"let A = 1; class B extends A {}", "cannot extend INTEGER, it is not a class"},
+		{"class B extends Missing {}", "identifier not found: Missing"},
+		{"class A { fn f(x) { x } } A().f()", "wrong number of arguments: want=1, got=0"},
+		{"let i = 0; while (i < 1) { let inner = 1; i += 1 } inner", "identifier not found: inner"},
+		{"match (missing) { _ => 1 }", "identifier not found: missing"},
+		{"match (1) { x if x + true => 1 }", "type mismatch: INTEGER + BOOLEAN"},
+		{"match (1) { _ => missing }", "identifier not found: missing"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%f, want=%f", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != NULL {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		big      bool
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808", true},
+		{"-9223372036854775808 - 1", "-9223372036854775809", true},
+		{"9223372036854775807 * 2", "18446744073709551614", true},
+		{"-9223372036854775808 / -1", "9223372036854775808", true},
+		{"-(-9223372036854775808)", "9223372036854775808", true},
+		{"123456789012345678901234567890", "123456789012345678901234567890", true},
+		{"2 ** 64", "18446744073709551616", true},
+		{"2 ** 10", "1024", false},
+		{"2 ** 3 ** 2", "512", false},
+		{"-2 ** 2", "-4", false},
+		{"2 ** 64 / 2 ** 32", "4294967296", false},
+		{"2 ** 64 - 2 ** 64 + 1", "1", false},
+		{"1 ** 1000000000000", "1", false},
+		{"-1 ** (2 ** 64 + 1)", "-1", false},
+		{"(-1) ** (2 ** 64 + 1)", "-1", false},
+		{"0 ** 1000000000000", "0", false},
+		{"123456789012345678901234567890 % 1000", "890", false},
+		{"-9223372036854775808", "-9223372036854775808", false},
+		{"let x = 9223372036854775807; x += 1; x", "9223372036854775808", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Type() != object.INTEGER_OBJ || evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got=%s (%T)", tt.input, tt.expected, evaluated.Inspect(), evaluated)
+			continue
+		}
+		if _, isBig := evaluated.(*object.BigInteger); isBig != tt.big {
+			t.Errorf("%s: wrong representation, got=%T", tt.input, evaluated)
+		}
+	}
+}
+
+func TestBigIntegerComparisons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"2 ** 64 > 1", true},
+		{"2 ** 64 < -(2 ** 64)", false},
+		{"2 ** 64 == 18446744073709551616", true},
+		{"2 ** 64 != 2 ** 64 + 1", true},
+		{"2 ** 64 / 2 ** 62 == 4", true},
+		{"2 ** 64 >= 2.0", true},
+		{"2 ** 64 * 0.5", 9223372036854775808.0},
+		{"2 ** -1", 0.5},
+		{"4 ** 0.5", 2.0},
+		{"[1, 2][2 ** 64]", nil},
+		{"[1, 2, 3][-(2 ** 64):2 ** 64]", "[1, 2, 3]"},
+		{`let d = {4: "four", 18446744073709551616: "big"}; d[2 ** 64 / 2 ** 62] + d[2 ** 64]`, "fourbig"},
+		{`let d = {2 ** 64: "big"}; d[2 ** 64 + 1]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("%s: expected %s, got=%s", tt.input, expected, evaluated.Inspect())
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestErrorDiagnostics(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedCode diagnostic.Code
+		expectedSpan string
+	}{
+		{"5 + true", diagnostic.TypeMismatch, "1:1-1:9"},
+		{"let a = 1;\nlet b = a + missing", diagnostic.UndefinedIdentifier, "2:13-2:20"},
+		{"fn f(x) {\n  x / 0\n}\nf(1)", diagnostic.DivisionByZero, "2:3-2:8"},
+		{"[1, 2][\"a\"]", diagnostic.InvalidIndex, "1:1-1:12"},
+		{"{[1]: 2}", diagnostic.UnhashableKey, "1:1-1:9"},
+		{"let a = 5; a(1)", diagnostic.NotCallable, "1:12-1:16"},
+		{"for (x in 5) { x }", diagnostic.NotIterable, "1:1-1:19"},
+		{"-true", diagnostic.UnknownOperator, "1:1-1:6"},
+		{"undeclared = 1", diagnostic.UndeclaredAssignment, "1:1-1:15"},
+		{"struct P { x }\nP{x: 1, y: 2}", diagnostic.UnknownField, "2:9-2:10"},
+		{"struct P { x }\nP{}", diagnostic.MissingField, "2:1-2:4"},
+		{"struct P { x }\nP{x: 1}.y", diagnostic.UnknownField, "2:1-2:10"},
+		{"let A = 1;\nclass B extends A {}", diagnostic.NotAClass, "2:17-2:18"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		d := errObj.Diagnostic()
+		if d.Code != tt.expectedCode {
+			t.Errorf("%q: wrong code. expected=%s, got=%s", tt.input, tt.expectedCode, d.Code)
+		}
+		if d.Span.String() != tt.expectedSpan {
+			t.Errorf("%q: wrong span. expected=%s, got=%s", tt.input, tt.expectedSpan, d.Span)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package evaluator
+
+import (
+	"af/src/ast"
+	"af/src/diagnostic"
+	"af/src/object"
+	"fmt"
+	"strconv"
+)
+
+// arms are tried in order, the first one whose pattern matches and whose guard is truthy
+// gives the result. Like an if without else, a match where no arm is taken evaluates to null
+func evalMatchExpression(node *ast.MatchExpression, env *object.Environment) object.Object {
+	value := Eval(node.Value, env)
+	if isError(value) || isSignal(value) {
+		return value
+	}
+	for _, arm := range node.Arms {
+		bindings := map[string]object.Object{}
+		if destructure(arm.Pattern, value, "value", env, bindings) != nil {
+			continue
+		}
+		armEnv := object.NewEnclosedEnvironment(env)
+		for name, bound := range bindings {
+			armEnv.Set(name, bound)
+		}
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isError(guard) || isSignal(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+		return Eval(arm.Body, armEnv)
+	}
+	return NULL
+}
+
+// binds the names of the pattern in env once the whole value matched it, so a failed
+// let leaves no names half bound
+func bindPattern(pattern ast.Pattern, value object.Object, path string, env *object.Environment, constant bool) *object.Error {
+	bindings := map[string]object.Object{}
+	if err := destructure(pattern, value, path, env, bindings); err != nil {
+		return err
+	}
+	for name := range bindings {
+		if env.IsLocalConstant(name) {
+			return newError(diagnostic.ConstantAssignment, "cannot redeclare constant %s", name)
+		}
+	}
+	for name, bound := range bindings {
+		declare(env, name, bound, constant)
+	}
+	return nil
+}
+
+// checks that value has the shape of the pattern and adds the names it binds to bindings.
+// A value that doesn't match gives a PatternMismatch error spanning the part of the pattern
+// that failed, path is how the matched part of the value is reached, like value[1]["name"]
+func destructure(pattern ast.Pattern, value object.Object, path string, env *object.Environment, bindings map[string]object.Object) *object.Error {
+	var err *object.Error
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+	case *ast.BindingPattern:
+		bindings[pattern.Name.Value] = value
+	case *ast.ValuePattern:
+		if evalInfixExpression("==", value, Eval(pattern.Value, env)) != TRUE {
+			err = newError(diagnostic.PatternMismatch, "expected %s at %s, got %s", pattern.PrintAsString(), path, describeValue(value))
+		}
+	case *ast.ArrayPattern:
+		err = destructureArray(pattern, value, path, env, bindings)
+	case *ast.HashPattern:
+		err = destructureHash(pattern, value, path, env, bindings)
+	}
+	if err != nil && err.Span.Start.Line == 0 {
+		err.Span = pattern.Span()
+	}
+	return err
+}
+
+// elements before the rest pattern match from the start of the array and the ones after it from the end
+func destructureArray(pattern *ast.ArrayPattern, value object.Object, path string, env *object.Environment, bindings map[string]object.Object) *object.Error {
+	array, ok := value.(*object.Array)
+	if !ok {
+		return newError(diagnostic.PatternMismatch, "expected an array at %s, got %s", path, value.Type())
+	}
+	restIndex := -1
+	for i, element := range pattern.Elements {
+		if _, ok := element.(*ast.RestPattern); ok {
+			restIndex = i
+		}
+	}
+	length := len(array.Elements)
+	fixed := len(pattern.Elements) // elements matched one by one
+	if restIndex >= 0 {
+		fixed--
+		if length < fixed {
+			return newError(diagnostic.PatternMismatch, "expected at least %d elements at %s, got %d", fixed, path, length)
+		}
+	} else if length != fixed {
+		return newError(diagnostic.PatternMismatch, "expected %d elements at %s, got %d", fixed, path, length)
+	}
+
+	for i, element := range pattern.Elements {
+		if rest, ok := element.(*ast.RestPattern); ok {
+			remaining := array.Elements[i : i+length-fixed]
+			if rest.Name.Value != "_" {
+				bindings[rest.Name.Value] = &object.Array{Elements: append([]object.Object{}, remaining...)}
+			}
+			continue
+		}
+		index := i
+		if restIndex >= 0 && i > restIndex {
+			index = i - 1 + length - fixed
+		}
+		elementPath := fmt.Sprintf("%s[%d]", path, index)
+		if err := destructure(element, array.Elements[index], elementPath, env, bindings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dictionaries match when they have every key of the pattern, structs and instances
+// when they have every field named by the string keys
+func destructureHash(pattern *ast.HashPattern, value object.Object, path string, env *object.Environment, bindings map[string]object.Object) *object.Error {
+	switch value.(type) {
+	case *object.Hash, *object.Struct, *object.Instance:
+	default:
+		return newError(diagnostic.PatternMismatch, "expected a dictionary at %s, got %s", path, value.Type())
+	}
+	for _, pair := range pattern.Pairs {
+		key := Eval(pair.Key, env)
+		member, memberPath, ok := lookUpPatternKey(value, key, path)
+		if !ok {
+			err := newError(diagnostic.PatternMismatch, "missing %s at %s", pair.Key.PrintAsString(), path)
+			err.Span = pair.Key.Span()
+			return err
+		}
+		if err := destructure(pair.Value, member, memberPath, env, bindings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the value stored under key and the path to it, dictionaries are indexed and fields are accessed with a dot
+func lookUpPatternKey(value object.Object, key object.Object, path string) (object.Object, string, bool) {
+	var fields map[string]object.Object
+	switch value := value.(type) {
+	case *object.Hash:
+		member, found := value.Get(key.(object.Hashable))
+		return member, path + "[" + describeValue(key) + "]", found
+	case *object.Struct:
+		fields = value.Fields
+	case *object.Instance:
+		fields = value.Fields
+	}
+	name, ok := key.(*object.String)
+	if !ok {
+		return nil, "", false
+	}
+	member, found := fields[name.Value]
+	return member, path + "." + name.Value, found
+}
+
+// strings are quoted so "1" and 1 are told apart in mismatch errors
+func describeValue(value object.Object) string {
+	if str, ok := value.(*object.String); ok {
+		return strconv.Quote(str.Value)
+	}
+	return value.Inspect()
+}
@@ -0,0 +1,304 @@
+package evaluator
+
+import (
+	"af/src/diagnostic"
+	"af/src/object"
+	"strings"
+)
+
+// built-in method of the receiver bound to it, nil if the type has no method with that name
+func lookUpMethod(receiver object.Object, name string) *object.Builtin {
+	var fn object.BuiltinFunction
+	switch receiver := receiver.(type) {
+	case *object.String:
+		fn = stringMethod(receiver, name)
+	case *object.Array:
+		fn = arrayMethod(receiver, name)
+	case *object.Hash:
+		fn = hashMethod(receiver, name)
+	}
+	if fn == nil {
+		return nil
+	}
+	return &object.Builtin{Name: string(receiver.Type()) + "." + name, Fn: fn}
+}
+
+func stringMethod(s *object.String, name string) object.BuiltinFunction {
+	switch name {
+	case "len":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args); err != nil {
+				return err
+			}
+			return &object.Integer{Value: int64(len([]rune(s.Value)))}
+		}
+	case "upper":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ToUpper(s.Value)}
+		}
+	case "lower":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ToLower(s.Value)}
+		}
+	case "trim":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.TrimSpace(s.Value)}
+		}
+	case "split":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			parts := strings.Split(s.Value, args[0].(*object.String).Value)
+			elements := make([]object.Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &object.String{Value: part}
+			}
+			return &object.Array{Elements: elements}
+		}
+	case "contains":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(strings.Contains(s.Value, args[0].(*object.String).Value))
+		}
+	case "startsWith":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(strings.HasPrefix(s.Value, args[0].(*object.String).Value))
+		}
+	case "endsWith":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(strings.HasSuffix(s.Value, args[0].(*object.String).Value))
+		}
+	case "replace":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			old, replacement := args[0].(*object.String).Value, args[1].(*object.String).Value
+			return &object.String{Value: strings.ReplaceAll(s.Value, old, replacement)}
+		}
+	}
+	return nil
+}
+
+func arrayMethod(arr *object.Array, name string) object.BuiltinFunction {
+	switch name {
+	case "len":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args); err != nil {
+				return err
+			}
+			return &object.Integer{Value: int64(len(arr.Elements))}
+		}
+	case "push":
+		// adds to the end of the array in place
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args, anyType); err != nil {
+				return err
+			}
+			arr.Elements = append(arr.Elements, args[0])
+			return NULL
+		}
+	case "pop":
+		// removes the last element and returns it, null when the array is empty
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args); err != nil {
+				return err
+			}
+			if len(arr.Elements) == 0 {
+				return NULL
+			}
+			last := arr.Elements[len(arr.Elements)-1]
+			arr.Elements = arr.Elements[:len(arr.Elements)-1]
+			return last
+		}
+	case "contains":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args, anyType); err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(indexOf(arr, args[0]) >= 0)
+		}
+	case "indexOf":
+		// -1 when the value is not in the array
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args, anyType); err != nil {
+				return err
+			}
+			return &object.Integer{Value: int64(indexOf(arr, args[0]))}
+		}
+	case "join":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			parts := make([]string, len(arr.Elements))
+			for i, element := range arr.Elements {
+				parts[i] = element.Inspect()
+			}
+			return &object.String{Value: strings.Join(parts, args[0].(*object.String).Value)}
+		}
+	case "reverse":
+		// returns a new array, the receiver is left untouched
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args); err != nil {
+				return err
+			}
+			elements := make([]object.Object, len(arr.Elements))
+			for i, element := range arr.Elements {
+				elements[len(elements)-1-i] = element
+			}
+			return &object.Array{Elements: elements}
+		}
+	case "map":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args, anyType); err != nil {
+				return err
+			}
+			elements := make([]object.Object, len(arr.Elements))
+			for i, element := range arr.Elements {
+				result := applyFunction(args[0], []object.Object{element})
+				if isError(result) {
+					return result
+				}
+				elements[i] = result
+			}
+			return &object.Array{Elements: elements}
+		}
+	case "filter":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args, anyType); err != nil {
+				return err
+			}
+			elements := []object.Object{}
+			for _, element := range arr.Elements {
+				keep := applyFunction(args[0], []object.Object{element})
+				if isError(keep) {
+					return keep
+				}
+				if isTruthy(keep) {
+					elements = append(elements, element)
+				}
+			}
+			return &object.Array{Elements: elements}
+		}
+	}
+	return nil
+}
+
+func hashMethod(hash *object.Hash, name string) object.BuiltinFunction {
+	switch name {
+	case "len":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args); err != nil {
+				return err
+			}
+			return &object.Integer{Value: int64(len(hash.Keys))}
+		}
+	case "keys":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args); err != nil {
+				return err
+			}
+			keys := make([]object.Object, len(hash.Keys))
+			for i, key := range hash.Keys {
+				keys[i] = hash.Pairs[key].Key
+			}
+			return &object.Array{Elements: keys}
+		}
+	case "values":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args); err != nil {
+				return err
+			}
+			values := make([]object.Object, len(hash.Keys))
+			for i, key := range hash.Keys {
+				values[i] = hash.Pairs[key].Value
+			}
+			return &object.Array{Elements: values}
+		}
+	case "contains":
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args, anyType); err != nil {
+				return err
+			}
+			key, ok := args[0].(object.Hashable)
+			if !ok {
+				return newError(diagnostic.UnhashableKey, "unusable as hash key: %s", args[0].Type())
+			}
+			_, found := hash.Get(key)
+			return nativeBoolToBooleanObject(found)
+		}
+	case "get":
+		// like indexing, with the second argument returned when the key is missing
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args, anyType, anyType); err != nil {
+				return err
+			}
+			key, ok := args[0].(object.Hashable)
+			if !ok {
+				return newError(diagnostic.UnhashableKey, "unusable as hash key: %s", args[0].Type())
+			}
+			if value, found := hash.Get(key); found {
+				return value
+			}
+			return args[1]
+		}
+	case "remove":
+		// deletes the key in place and returns whether it was there
+		return func(args ...object.Object) object.Object {
+			if err := checkArguments(name, args, anyType); err != nil {
+				return err
+			}
+			key, ok := args[0].(object.Hashable)
+			if !ok {
+				return newError(diagnostic.UnhashableKey, "unusable as hash key: %s", args[0].Type())
+			}
+			return nativeBoolToBooleanObject(hash.Delete(key))
+		}
+	}
+	return nil
+}
+
+// accepted by checkArguments for parameters that take any value
+const anyType object.ObjectType = ""
+
+// checks the number of arguments and their types, returns nil when they match
+func checkArguments(name string, args []object.Object, types ...object.ObjectType) *object.Error {
+	if len(args) != len(types) {
+		return newError(diagnostic.WrongArgumentCount, "wrong number of arguments to %s: want=%d, got=%d", name, len(types), len(args))
+	}
+	for i, want := range types {
+		if want != anyType && args[i].Type() != want {
+			return newError(diagnostic.TypeMismatch, "argument %d to %s must be %s, got %s", i+1, name, want, args[i].Type())
+		}
+	}
+	return nil
+}
+
+// position of the first element equal to value, -1 if there is none
+func indexOf(arr *object.Array, value object.Object) int {
+	for i, element := range arr.Elements {
+		if evalInfixExpression("==", element, value) == TRUE {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,118 @@
+package evaluator
+
+import (
+	"af/src/ast"
+	"af/src/diagnostic"
+	"af/src/object"
+)
+
+func evalStructStatement(node *ast.StructStatement, env *object.Environment) object.Object {
+	fields := make([]string, len(node.Fields))
+	for i, field := range node.Fields {
+		fields[i] = field.Value
+	}
+	if err := declare(env, node.Name.Value, &object.StructType{Name: node.Name.Value, Fields: fields}, false); err != nil {
+		return err
+	}
+	return NULL
+}
+
+// every field of the definition has to be given a value, the values are evaluated in the written order
+func evalStructLiteral(node *ast.StructLiteral, env *object.Environment) object.Object {
+	definition := evalIdentifier(node.Name, env)
+	if isError(definition) {
+		return definition
+	}
+	structType, ok := definition.(*object.StructType)
+	if !ok {
+		return newError(diagnostic.NotAStruct, "not a struct: %s", definition.Type())
+	}
+
+	instance := &object.Struct{Definition: structType, Fields: make(map[string]object.Object)}
+	for _, field := range node.Fields {
+		if !structType.HasField(field.Name.Value) {
+			err := unknownFieldError(structType, field.Name.Value)
+			err.Span = field.Name.Span()
+			return err
+		}
+		value := Eval(field.Value, env)
+		if isError(value) || isSignal(value) {
+			return value
+		}
+		instance.Fields[field.Name.Value] = value
+	}
+	for _, name := range structType.Fields {
+		if _, ok := instance.Fields[name]; !ok {
+			return newError(diagnostic.MissingField, "missing field %s in %s literal", name, structType.Name)
+		}
+	}
+	return instance
+}
+
+// structs are equal when they share the definition and their fields are equal,
+// so nested structs are compared by value too
+func structsEqual(left, right *object.Struct) bool {
+	return structFieldsEqual(left, right, map[[2]object.Object]bool{})
+}
+
+func structFieldsEqual(left, right *object.Struct, comparing map[[2]object.Object]bool) bool {
+	if left.Definition != right.Definition {
+		return false
+	}
+	for _, name := range left.Definition.Fields {
+		if !fieldsEqual(left.Fields[name], right.Fields[name], comparing) {
+			return false
+		}
+	}
+	return true
+}
+
+// arrays and dictionaries in fields are compared element by element instead of by identity,
+// other values like ==. A container can hold itself, comparing holds the pairs already being
+// compared further up, meeting one again adds nothing new so it counts as equal
+func fieldsEqual(left, right object.Object, comparing map[[2]object.Object]bool) bool {
+	if left == right {
+		return true
+	}
+	pair := [2]object.Object{left, right}
+	switch left.(type) {
+	case *object.Array, *object.Hash, *object.Struct:
+		if comparing[pair] {
+			return true
+		}
+		comparing[pair] = true
+	}
+	switch left := left.(type) {
+	case *object.Array:
+		right, ok := right.(*object.Array)
+		if !ok || len(left.Elements) != len(right.Elements) {
+			return false
+		}
+		for i, element := range left.Elements {
+			if !fieldsEqual(element, right.Elements[i], comparing) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		right, ok := right.(*object.Hash)
+		if !ok || len(left.Keys) != len(right.Keys) {
+			return false
+		}
+		for _, key := range left.Keys {
+			other, found := right.Pairs[key]
+			if !found || !fieldsEqual(left.Pairs[key].Value, other.Value, comparing) {
+				return false
+			}
+		}
+		return true
+	case *object.Struct:
+		right, ok := right.(*object.Struct)
+		return ok && structFieldsEqual(left, right, comparing)
+	}
+	return evalInfixExpression("==", left, right) == TRUE
+}
+
+func unknownFieldError(structType *object.StructType, name string) *object.Error {
+	return newError(diagnostic.UnknownField, "struct %s has no field %s", structType.Name, name)
+}
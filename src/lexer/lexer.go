@@ -1,38 +1,97 @@
 package lexer
 
-import "af/src/token"
+import (
+	"af/src/diagnostic"
+	"af/src/token"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
 type Lexer struct {
 	input        string
-	position     int  // position in the input
-	nextPosition int  // next position
-	currentValue byte // char at the current position
+	position     int                     // position in the input
+	nextPosition int                     // next position
+	currentValue rune                    // char at the current position, decoded from UTF-8
+	line         int                     // line of the current char
+	column       int                     // column of the current char
+	start        token.Position          // position of the first char, not 1:1 when lexing a piece of a bigger source
+	diagnostics  []diagnostic.Diagnostic // errors found since the last call to TakeDiagnostics
 }
 
 func NewLexer(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewLexerAt(input, token.Position{Offset: 0, Line: 1, Column: 1})
+}
+
+// creates a lexer whose token positions start at start instead of the beginning of the file
+func NewLexerAt(input string, start token.Position) *Lexer {
+	l := &Lexer{input: input, start: start, line: start.Line, column: start.Column}
 	l.readChar() // sets initial values for position, nextPosition and currentValue
 	return l
 }
 
 // function to read current char and advance position
 func (l *Lexer) readChar() {
+	// line and column move past the char being left, a \r\n pair is a single line break
+	if l.nextPosition > 0 && l.position < len(l.input) {
+		if l.currentValue == '\n' || l.currentValue == '\r' && l.peekChar() != '\n' {
+			l.line++
+			l.column = 1
+		} else {
+			l.column++
+		}
+	}
+	l.position = l.nextPosition
 	if l.nextPosition >= len(l.input) {
 		l.currentValue = 0
+		l.nextPosition += 1
 	} else {
-		l.currentValue = l.input[l.nextPosition]
+		char, size := utf8.DecodeRuneInString(l.input[l.nextPosition:])
+		l.currentValue = char
+		l.nextPosition += size
 	}
-	l.position = l.nextPosition
-	l.nextPosition += 1
+}
+
+// returns the errors found while reading tokens and forgets them, so every error is reported once
+func (l *Lexer) TakeDiagnostics() []diagnostic.Diagnostic {
+	diagnostics := l.diagnostics
+	l.diagnostics = nil
+	return diagnostics
+}
+
+// returns the next n tokens, doc comments excluded, without consuming them.
+// Diagnostics found while reading ahead are dropped, they come back when the tokens are read again
+func (l *Lexer) PeekTokens(n int) []token.Token {
+	saved := *l
+	defer func() { *l = saved }()
+	tokens := []token.Token{}
+	for len(tokens) < n {
+		tok := l.NextToken()
+		if tok.Type != token.DOC_COMMENT {
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens
+}
+
+func (l *Lexer) report(d diagnostic.Diagnostic) {
+	l.diagnostics = append(l.diagnostics, d)
 }
 
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 	l.skipWhitespaces()
+	start := l.currentPosition()
 
 	switch l.currentValue {
 	case '+':
-		tok = newToken(token.PLUS, l.currentValue)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.PLUS_ASSIGN)
+		} else {
+			tok = newToken(token.PLUS, l.currentValue)
+		}
 	case '(':
 		tok = newToken(token.LPAREN, l.currentValue)
 	case ')':
@@ -41,38 +100,111 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACE, l.currentValue)
 	case '}':
 		tok = newToken(token.RBRACE, l.currentValue)
+	case '[':
+		tok = newToken(token.LBRACKET, l.currentValue)
+	case ']':
+		tok = newToken(token.RBRACKET, l.currentValue)
 	case ',':
 		tok = newToken(token.COMMA, l.currentValue)
+	case ':':
+		tok = newToken(token.COLON, l.currentValue)
+	case '.':
+		if strings.HasPrefix(l.input[l.position:], token.ELLIPSIS) {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: token.ELLIPSIS}
+		} else {
+			tok = newToken(token.DOT, l.currentValue)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.currentValue)
 	case '=':
 		if l.peekChar() == '=' {
-			current := l.currentValue
-			l.readChar()
-			literal := string(current) + string(l.currentValue)
-			tok = token.Token{Type: token.EQUAL, Literal: literal}
+			tok = l.readTwoCharToken(token.EQUAL)
+		} else if l.peekChar() == '>' {
+			tok = l.readTwoCharToken(token.ARROW)
 		} else {
 			tok = newToken(token.ASSIGN, l.currentValue)
 		}
 	case '!':
 		if l.peekChar() == '=' {
-			current := l.currentValue
-			l.readChar()
-			literal := string(current) + string(l.currentValue)
-			tok = token.Token{Type: token.NOT_EQUAL, Literal: literal}
+			tok = l.readTwoCharToken(token.NOT_EQUAL)
 		} else {
 			tok = newToken(token.BANG, l.currentValue)
 		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.readTwoCharToken(token.AND)
+		} else {
+			tok = l.illegalChar()
+		}
+	case '?':
+		switch l.peekChar() {
+		case '?':
+			tok = l.readTwoCharToken(token.NULLISH)
+		case '.':
+			tok = l.readTwoCharToken(token.OPTIONAL_CHAIN)
+		default:
+			tok = l.illegalChar()
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.readTwoCharToken(token.OR)
+		} else {
+			tok = l.illegalChar()
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.currentValue)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.MINUS_ASSIGN)
+		} else {
+			tok = newToken(token.MINUS, l.currentValue)
+		}
 	case '/':
-		tok = newToken(token.SLASH, l.currentValue)
+		if l.isDocComment() {
+			tok = token.Token{Type: token.DOC_COMMENT, Literal: l.readLineComment()}
+			tok.Span = token.Span{Start: start, End: l.currentPosition()}
+			return tok
+		} else if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.SLASH_ASSIGN)
+		} else {
+			tok = newToken(token.SLASH, l.currentValue)
+		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.currentValue)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.ASTERISK_ASSIGN)
+		} else if l.peekChar() == '*' {
+			tok = l.readTwoCharToken(token.POWER)
+		} else {
+			tok = newToken(token.ASTERISK, l.currentValue)
+		}
+	case '%':
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.MOD_ASSIGN)
+		} else {
+			tok = newToken(token.MOD, l.currentValue)
+		}
 	case '<':
-		tok = newToken(token.LT, l.currentValue)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.LT_EQ)
+		} else {
+			tok = newToken(token.LT, l.currentValue)
+		}
 	case '>':
-		tok = newToken(token.GT, l.currentValue)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.GT_EQ)
+		} else {
+			tok = newToken(token.GT, l.currentValue)
+		}
+	case '"':
+		literal, ok := l.readString()
+		if ok {
+			tok = token.Token{Type: token.STRING, Literal: literal}
+		} else {
+			tok = token.Token{Type: token.ILLEGAL, Literal: "\"" + literal}
+			d := diagnostic.Errorf(diagnostic.UnterminatedString, token.Span{Start: start, End: start.Advance("\"")}, "unterminated string")
+			d.Help = "add a \" at the end of the string"
+			l.report(d)
+		}
 
 	case 0:
 		tok.Type = token.EOF
@@ -81,68 +213,226 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.currentValue) {
 			tok.Literal = l.readIndentifier()
 			tok.Type = token.LookUpIdent(tok.Literal)
+			tok.Span = token.Span{Start: start, End: l.currentPosition()}
 			return tok
 		} else if isNumber(l.currentValue) {
 			tok.Literal = l.readNumber()
 			tok.Type = token.LookUpNumberType(tok.Literal)
+			if err := checkNumber(tok.Literal); err != nil {
+				span := token.Span{Start: start, End: l.currentPosition()}
+				l.report(diagnostic.Errorf(diagnostic.MalformedNumber, span, "malformed number %q: %s", tok.Literal, err))
+				tok.Type = token.ILLEGAL
+			}
+			tok.Span = token.Span{Start: start, End: l.currentPosition()}
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.currentValue)
+			tok = l.illegalChar()
 		}
 	}
 	l.readChar()
+	tok.Span = token.Span{Start: start, End: l.currentPosition()}
 	return tok
 }
 
-func newToken(tokenType token.TokenType, char byte) token.Token {
+// position of the current char, or of the end of the input once it has been consumed
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Offset: l.start.Offset + min(l.position, len(l.input)),
+		Line:   l.line,
+		Column: l.column,
+	}
+}
+
+func newToken(tokenType token.TokenType, char rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(char)}
 }
 
+// every ILLEGAL token gets its error here, the raw bytes are kept so invalid UTF-8 is reported as written
+func (l *Lexer) illegalChar() token.Token {
+	literal := l.input[l.position:l.nextPosition]
+	span := token.Span{Start: l.currentPosition(), End: l.currentPosition().Advance(literal)}
+	l.report(diagnostic.Errorf(diagnostic.UnexpectedCharacter, span, "unexpected character %q", literal))
+	return token.Token{Type: token.ILLEGAL, Literal: literal}
+}
+
+// builds a token from the current and the next char, leaving the position on the second one
+func (l *Lexer) readTwoCharToken(tokenType token.TokenType) token.Token {
+	current := l.currentValue
+	l.readChar()
+	return token.Token{Type: tokenType, Literal: string(current) + string(l.currentValue)}
+}
+
 // returns next char without advancing position
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.nextPosition >= len(l.input) {
 		return 0
 	} else {
-		return l.input[l.nextPosition]
+		char, _ := utf8.DecodeRuneInString(l.input[l.nextPosition:])
+		return char
 	}
 }
 
+// identifiers start with a letter or '_' and go on with letters, '_' and digits,
+// letters and digits are any Unicode ones so `año` and `value1` are single identifiers
 func (l *Lexer) readIndentifier() string {
 	var initialPosition = l.position
-	for isLetter(l.currentValue) {
+	for isLetter(l.currentValue) || unicode.IsDigit(l.currentValue) {
 		l.readChar()
 	}
 	return l.input[initialPosition:l.position]
 }
 
-func (l *Lexer) readNumber() string {
-	var initialPosition = l.position
-	var isFloatNumber = false
-	for isNumber(l.currentValue) {
+// reads the raw content between quotes, escape sequences and ${...} interpolations
+// are kept as written and decoded later by the parser. Returns false if the string is not terminated
+func (l *Lexer) readString() (string, bool) {
+	initialPosition := l.position + 1
+	for {
 		l.readChar()
+		switch l.currentValue {
+		case 0:
+			return l.input[initialPosition:l.position], false
+		case '"':
+			return l.input[initialPosition:l.position], true
+		case '\\':
+			if l.peekChar() == 0 {
+				l.readChar()
+				return l.input[initialPosition:l.position], false
+			}
+			l.readChar()
+		case '$':
+			if l.peekChar() == '{' {
+				l.readChar()
+				if !l.skipInterpolation() {
+					return l.input[initialPosition:l.position], false
+				}
+			}
+		}
 	}
-	if l.currentValue == '.' {
-		isFloatNumber = true
+}
+
+// advances until the '}' closing an interpolation, skipping nested braces and strings
+func (l *Lexer) skipInterpolation() bool {
+	depth := 1
+	for depth > 0 {
 		l.readChar()
-	}
-	if isFloatNumber {
-		for isNumber(l.currentValue) {
-			l.readChar()
+		switch l.currentValue {
+		case 0:
+			return false
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '"':
+			if _, ok := l.readString(); !ok {
+				return false
+			}
 		}
 	}
-	return l.input[initialPosition:l.position]
+	return true
 }
 
-func isLetter(ch byte) bool {
-	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_'
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
-func isNumber(ch byte) bool {
+// number literals only use ASCII digits
+func isNumber(ch rune) bool {
 	return ch >= '0' && ch <= '9'
 }
 
+// skips whitespaces and comments, doc comments are left in place to be read as tokens
 func (l *Lexer) skipWhitespaces() {
-	for l.currentValue == ' ' || l.currentValue == '\n' || l.currentValue == '\t' || l.currentValue == '\r' {
+	for {
+		switch {
+		case l.currentValue == ' ' || l.currentValue == '\n' || l.currentValue == '\t' || l.currentValue == '\r':
+			l.readChar()
+		case l.currentValue == '/' && l.peekChar() == '/' && !l.isDocComment():
+			l.readLineComment()
+		case l.currentValue == '/' && l.peekChar() == '*':
+			l.skipBlockComment()
+		default:
+			return
+		}
+	}
+}
+
+// a doc comment starts with exactly three slashes, four or more are a regular comment
+func (l *Lexer) isDocComment() bool {
+	rest := l.input[l.position:]
+	return strings.HasPrefix(rest, "///") && !strings.HasPrefix(rest, "////")
+}
+
+// reads from the slashes to the end of the line and returns the comment text without them
+func (l *Lexer) readLineComment() string {
+	initialPosition := l.position
+	for l.currentValue != '\n' && l.currentValue != '\r' && l.currentValue != 0 {
 		l.readChar()
 	}
+	return strings.TrimSpace(strings.TrimLeft(l.input[initialPosition:l.position], "/"))
+}
+
+// skips a /* */ comment, comments can be nested so each /* needs its own */
+func (l *Lexer) skipBlockComment() {
+	start := l.currentPosition()
+	depth := 0
+	for {
+		switch {
+		case l.currentValue == 0:
+			d := diagnostic.Errorf(diagnostic.UnterminatedComment, token.Span{Start: start, End: start.Advance("/*")}, "unterminated block comment")
+			d.Help = "block comments can be nested, every /* needs its own */"
+			l.report(d)
+			return
+		case l.currentValue == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.currentValue == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+			if depth == 0 {
+				l.readChar()
+				return
+			}
+		}
+		l.readChar()
+	}
+}
+
+// decodes the escape sequences of a raw string literal: \n \t \r \" \\ \$ and \u{hex}
+func Unescape(raw string) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' {
+			out.WriteByte(raw[i])
+			continue
+		}
+		i++
+		if i >= len(raw) {
+			return "", fmt.Errorf("unfinished escape sequence")
+		}
+		switch raw[i] {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case '"', '\\', '$':
+			out.WriteByte(raw[i])
+		case 'u':
+			end := strings.IndexByte(raw[i:], '}')
+			if i+1 >= len(raw) || raw[i+1] != '{' || end < 0 {
+				return "", fmt.Errorf("unicode escape must be written as \\u{hex}")
+			}
+			hex := raw[i+2 : i+end]
+			code, err := strconv.ParseUint(hex, 16, 32)
+			if err != nil || len(hex) == 0 || len(hex) > 6 || !utf8.ValidRune(rune(code)) {
+				return "", fmt.Errorf("invalid unicode escape \\u{%s}", hex)
+			}
+			out.WriteRune(rune(code))
+			i += end
+		default:
+			return "", fmt.Errorf("unknown escape sequence \\%c", raw[i])
+		}
+	}
+	return out.String(), nil
 }
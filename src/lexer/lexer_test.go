@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"af/src/diagnostic"
 	"af/src/token"
 	"testing"
 )
@@ -14,7 +15,7 @@ func TestTokens(t *testing.T) {
 	}
 	let result = addFn(numVariable, otherVariable);
 
-	!-/*5;
+	!-/ *5;
 	5 < 10 > 5;
 
 	if (5 < 10) {
@@ -118,3 +119,553 @@ func TestTokens(t *testing.T) {
 	}
 
 }
+
+func TestStringTokens(t *testing.T) {
+	input := `"foobar" "foo bar" "line\n\"quoted\"" "Hello ${name}!" "${d["key"]}" ""
+	"unterminated`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, "foobar"},
+		{token.STRING, "foo bar"},
+		{token.STRING, `line\n\"quoted\"`},
+		{token.STRING, "Hello ${name}!"},
+		{token.STRING, `${d["key"]}`},
+		{token.STRING, ""},
+		{token.ILLEGAL, `"unterminated`},
+		{token.EOF, ""},
+	}
+	lexer := NewLexer(input)
+
+	for index, testValue := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != testValue.expectedType {
+			t.Fatalf("Tests [%d] - tokentype wrong. Expected=%v , got=%v", index, testValue.expectedType, tok.Type)
+		}
+
+		if tok.Literal != testValue.expectedLiteral {
+			t.Fatalf("Tests [%d] - literal wrong. Expected=%v , got=%v", index, testValue.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		isError  bool
+	}{
+		{`hello`, "hello", false},
+		{`a\nb\tc`, "a\nb\tc", false},
+		{`\"quoted\" \\ \$`, `"quoted" \ $`, false},
+		{`\u{41}\u{f1}\u{1F600}`, "Añ😀", false},
+		{`\q`, "", true},
+		{`\u41`, "", true},
+		{`\u{}`, "", true},
+		{`\u{110000}`, "", true},
+	}
+
+	for _, tt := range tests {
+		value, err := Unescape(tt.input)
+		if tt.isError {
+			if err == nil {
+				t.Errorf("Unescape(%q) expected error, got %q", tt.input, value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unescape(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if value != tt.expected {
+			t.Errorf("Unescape(%q) wrong. Expected=%q , got=%q", tt.input, tt.expected, value)
+		}
+	}
+}
+
+func TestCollectionTokens(t *testing.T) {
+	input := `["hello", "world"][1:2]
+	{"name": "Ander"}`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LBRACKET, "["},
+		{token.STRING, "hello"},
+		{token.COMMA, ","},
+		{token.STRING, "world"},
+		{token.RBRACKET, "]"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COLON, ":"},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.LBRACE, "{"},
+		{token.STRING, "name"},
+		{token.COLON, ":"},
+		{token.STRING, "Ander"},
+		{token.RBRACE, "}"},
+		{token.EOF, ""},
+	}
+	lexer := NewLexer(input)
+
+	for index, testValue := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != testValue.expectedType {
+			t.Fatalf("Tests [%d] - tokentype wrong. Expected=%v , got=%v", index, testValue.expectedType, tok.Type)
+		}
+
+		if tok.Literal != testValue.expectedLiteral {
+			t.Fatalf("Tests [%d] - literal wrong. Expected=%v , got=%v", index, testValue.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestOperatorTokens(t *testing.T) {
+	input := `a <= b >= c && d || !e & | a % b ** c *= d * e`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.AND, "&&"},
+		{token.IDENT, "d"},
+		{token.OR, "||"},
+		{token.BANG, "!"},
+		{token.IDENT, "e"},
+		{token.ILLEGAL, "&"},
+		{token.ILLEGAL, "|"},
+		{token.IDENT, "a"},
+		{token.MOD, "%"},
+		{token.IDENT, "b"},
+		{token.POWER, "**"},
+		{token.IDENT, "c"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.IDENT, "d"},
+		{token.ASTERISK, "*"},
+		{token.IDENT, "e"},
+		{token.EOF, ""},
+	}
+	lexer := NewLexer(input)
+
+	for index, testValue := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != testValue.expectedType {
+			t.Fatalf("Tests [%d] - tokentype wrong. Expected=%v , got=%v", index, testValue.expectedType, tok.Type)
+		}
+
+		if tok.Literal != testValue.expectedLiteral {
+			t.Fatalf("Tests [%d] - literal wrong. Expected=%v , got=%v", index, testValue.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestLoopTokens(t *testing.T) {
+	input := `while (x) { break; } for (k, v in d) { continue }`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.WHILE, "while"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.BREAK, "break"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.FOR, "for"},
+		{token.LPAREN, "("},
+		{token.IDENT, "k"},
+		{token.COMMA, ","},
+		{token.IDENT, "v"},
+		{token.IN, "in"},
+		{token.IDENT, "d"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.CONTINUE, "continue"},
+		{token.RBRACE, "}"},
+		{token.EOF, ""},
+	}
+	lexer := NewLexer(input)
+
+	for index, testValue := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != testValue.expectedType {
+			t.Fatalf("Tests [%d] - tokentype wrong. Expected=%v , got=%v", index, testValue.expectedType, tok.Type)
+		}
+
+		if tok.Literal != testValue.expectedLiteral {
+			t.Fatalf("Tests [%d] - literal wrong. Expected=%v , got=%v", index, testValue.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestAssignmentTokens(t *testing.T) {
+	input := `x = 1; x += 2; x -= 3; x *= 4; x /= 5; x %= 6; arr[0] = x`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "4"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MOD_ASSIGN, "%="},
+		{token.INT, "6"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "arr"},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+	lexer := NewLexer(input)
+
+	for index, testValue := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != testValue.expectedType {
+			t.Fatalf("Tests [%d] - tokentype wrong. Expected=%v , got=%v", index, testValue.expectedType, tok.Type)
+		}
+
+		if tok.Literal != testValue.expectedLiteral {
+			t.Fatalf("Tests [%d] - literal wrong. Expected=%v , got=%v", index, testValue.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNullTokens(t *testing.T) {
+	input := `null a ?? b c?.d e?.[0] ?`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.NULL, "null"},
+		{token.IDENT, "a"},
+		{token.NULLISH, "??"},
+		{token.IDENT, "b"},
+		{token.IDENT, "c"},
+		{token.OPTIONAL_CHAIN, "?."},
+		{token.IDENT, "d"},
+		{token.IDENT, "e"},
+		{token.OPTIONAL_CHAIN, "?."},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.ILLEGAL, "?"},
+		{token.EOF, ""},
+	}
+	lexer := NewLexer(input)
+
+	for index, testValue := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != testValue.expectedType {
+			t.Fatalf("Tests [%d] - tokentype wrong. Expected=%v , got=%v", index, testValue.expectedType, tok.Type)
+		}
+
+		if tok.Literal != testValue.expectedLiteral {
+			t.Fatalf("Tests [%d] - literal wrong. Expected=%v , got=%v", index, testValue.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestMatchTokens(t *testing.T) {
+	input := `match (x) { [a, ...rest] => a.b, _ if x >= 1 => 2 } .. ==>`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.MATCH, "match"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.LBRACKET, "["},
+		{token.IDENT, "a"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RBRACKET, "]"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "a"},
+		{token.DOT, "."},
+		{token.IDENT, "b"},
+		{token.COMMA, ","},
+		{token.IDENT, "_"},
+		{token.IF, "if"},
+		{token.IDENT, "x"},
+		{token.GT_EQ, ">="},
+		{token.INT, "1"},
+		{token.ARROW, "=>"},
+		{token.INT, "2"},
+		{token.RBRACE, "}"},
+		{token.DOT, "."},
+		{token.DOT, "."},
+		{token.EQUAL, "=="},
+		{token.GT, ">"},
+		{token.EOF, ""},
+	}
+	lexer := NewLexer(input)
+
+	for index, testValue := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != testValue.expectedType {
+			t.Fatalf("Tests [%d] - tokentype wrong. Expected=%v , got=%v", index, testValue.expectedType, tok.Type)
+		}
+
+		if tok.Literal != testValue.expectedLiteral {
+			t.Fatalf("Tests [%d] - literal wrong. Expected=%v , got=%v", index, testValue.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 10;\r\n  x >= \"a\nb\"\n\tfoo"
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedSpan string
+		offset       int
+	}{
+		{token.LET, "1:1-1:4", 0},
+		{token.IDENT, "1:5-1:6", 4},
+		{token.ASSIGN, "1:7-1:8", 6},
+		{token.INT, "1:9-1:11", 8},
+		{token.SEMICOLON, "1:11-1:12", 10},
+		{token.IDENT, "2:3-2:4", 15},
+		{token.GT_EQ, "2:5-2:7", 17},
+		{token.STRING, "2:8-3:3", 20},
+		{token.IDENT, "4:2-4:5", 27},
+		{token.EOF, "4:5-4:5", 30},
+	}
+	lexer := NewLexer(input)
+
+	for index, testValue := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != testValue.expectedType {
+			t.Fatalf("Tests [%d] - tokentype wrong. Expected=%v , got=%v", index, testValue.expectedType, tok.Type)
+		}
+
+		if tok.Span.String() != testValue.expectedSpan {
+			t.Fatalf("Tests [%d] - span wrong. Expected=%v , got=%v", index, testValue.expectedSpan, tok.Span)
+		}
+
+		if tok.Span.Start.Offset != testValue.offset {
+			t.Fatalf("Tests [%d] - offset wrong. Expected=%v , got=%v", index, testValue.offset, tok.Span.Start.Offset)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// variables + dataTypes
+	let x = 1; // trailing comment
+	/* block */ x /* nested /* comment */ still comment */ + 2
+	//// not a doc comment
+	/// Adds two numbers.
+	///
+	fn x / /*/ */ y
+	/* unterminated /* */`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.INT, "2"},
+		{token.DOC_COMMENT, "Adds two numbers."},
+		{token.DOC_COMMENT, ""},
+		{token.FUNCTION, "fn"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.IDENT, "y"},
+		{token.EOF, ""},
+	}
+	lexer := NewLexer(input)
+
+	for index, testValue := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != testValue.expectedType {
+			t.Fatalf("Tests [%d] - tokentype wrong. Expected=%v , got=%v", index, testValue.expectedType, tok.Type)
+		}
+
+		if tok.Literal != testValue.expectedLiteral {
+			t.Fatalf("Tests [%d] - literal wrong. Expected=%v , got=%v", index, testValue.expectedLiteral, tok.Literal)
+		}
+	}
+
+	diagnostics := lexer.TakeDiagnostics()
+	if len(diagnostics) != 1 || diagnostics[0].String() != "8:2: unterminated block comment" {
+		t.Fatalf("expected unterminated block comment error, got=%v", diagnostics)
+	}
+	if diagnostics[0].Code != diagnostic.UnterminatedComment || diagnostics[0].Span.String() != "8:2-8:4" {
+		t.Fatalf("wrong code or span, got=%s %s", diagnostics[0].Code, diagnostics[0].Span)
+	}
+	if diagnostics := lexer.TakeDiagnostics(); len(diagnostics) != 0 {
+		t.Fatalf("errors were not cleared, got=%v", diagnostics)
+	}
+}
+
+func TestUnicodeTokens(t *testing.T) {
+	input := "let año = value1 + _x2;\n\"héllo\" 日本 ¿ \xff 9lives"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedSpan    string
+		offset          int
+	}{
+		{token.LET, "let", "1:1-1:4", 0},
+		{token.IDENT, "año", "1:5-1:8", 4},
+		{token.ASSIGN, "=", "1:9-1:10", 9},
+		{token.IDENT, "value1", "1:11-1:17", 11},
+		{token.PLUS, "+", "1:18-1:19", 18},
+		{token.IDENT, "_x2", "1:20-1:23", 20},
+		{token.SEMICOLON, ";", "1:23-1:24", 23},
+		{token.STRING, "héllo", "2:1-2:8", 25},
+		{token.IDENT, "日本", "2:9-2:11", 34},
+		{token.ILLEGAL, "¿", "2:12-2:13", 41},
+		{token.ILLEGAL, "\xff", "2:14-2:15", 44},
+		{token.ILLEGAL, "9lives", "2:16-2:22", 46},
+		{token.EOF, "", "2:22-2:22", 52},
+	}
+	lexer := NewLexer(input)
+
+	for index, testValue := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != testValue.expectedType {
+			t.Fatalf("Tests [%d] - tokentype wrong. Expected=%v , got=%v", index, testValue.expectedType, tok.Type)
+		}
+
+		if tok.Literal != testValue.expectedLiteral {
+			t.Fatalf("Tests [%d] - literal wrong. Expected=%q , got=%q", index, testValue.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Span.String() != testValue.expectedSpan {
+			t.Fatalf("Tests [%d] - span wrong. Expected=%v , got=%v", index, testValue.expectedSpan, tok.Span)
+		}
+
+		if tok.Span.Start.Offset != testValue.offset {
+			t.Fatalf("Tests [%d] - offset wrong. Expected=%v , got=%v", index, testValue.offset, tok.Span.Start.Offset)
+		}
+	}
+}
+
+func TestNumberTokens(t *testing.T) {
+	input := `0 42 0xFF 0XaB_cd 0b1010 0o17 1_000_000 1.5 1_000.25 1.5e-3 2E10 6e+2
+	1.2.3 0x 0b102 0o8 1__0 1_ 017 1e 1.5e+ 12abc 0xFFe 5.foo`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "0"},
+		{token.INT, "42"},
+		{token.INT, "0xFF"},
+		{token.INT, "0XaB_cd"},
+		{token.INT, "0b1010"},
+		{token.INT, "0o17"},
+		{token.INT, "1_000_000"},
+		{token.FLOAT, "1.5"},
+		{token.FLOAT, "1_000.25"},
+		{token.FLOAT, "1.5e-3"},
+		{token.FLOAT, "2E10"},
+		{token.FLOAT, "6e+2"},
+		{token.ILLEGAL, "1.2.3"},
+		{token.ILLEGAL, "0x"},
+		{token.ILLEGAL, "0b102"},
+		{token.ILLEGAL, "0o8"},
+		{token.ILLEGAL, "1__0"},
+		{token.ILLEGAL, "1_"},
+		{token.ILLEGAL, "017"},
+		{token.ILLEGAL, "1e"},
+		{token.ILLEGAL, "1.5e+"},
+		{token.ILLEGAL, "12abc"},
+		{token.INT, "0xFFe"},
+		{token.INT, "5"},
+		{token.DOT, "."},
+		{token.IDENT, "foo"},
+		{token.EOF, ""},
+	}
+	lexer := NewLexer(input)
+
+	for index, testValue := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != testValue.expectedType {
+			t.Fatalf("Tests [%d] - tokentype wrong. Expected=%v , got=%v", index, testValue.expectedType, tok.Type)
+		}
+
+		if tok.Literal != testValue.expectedLiteral {
+			t.Fatalf("Tests [%d] - literal wrong. Expected=%v , got=%v", index, testValue.expectedLiteral, tok.Literal)
+		}
+	}
+
+	expectedErrors := []string{
+		`2:2: malformed number "1.2.3": too many decimal points`,
+		`2:8: malformed number "0x": hexadecimal literal has no digits`,
+		`2:11: malformed number "0b102": invalid digit '2' in binary literal`,
+		`2:17: malformed number "0o8": invalid digit '8' in octal literal`,
+		`2:21: malformed number "1__0": '_' must be placed between digits`,
+		`2:26: malformed number "1_": '_' must be placed between digits`,
+		`2:29: malformed number "017": leading zeros are not allowed, use 0o for octal`,
+		`2:33: malformed number "1e": exponent has no digits`,
+		`2:36: malformed number "1.5e+": exponent has no digits`,
+		`2:42: malformed number "12abc": invalid digit 'a' in number`,
+	}
+	diagnostics := lexer.TakeDiagnostics()
+	if len(diagnostics) != len(expectedErrors) {
+		t.Fatalf("wrong number of errors. Expected=%d, got=%d: %v", len(expectedErrors), len(diagnostics), diagnostics)
+	}
+	for i, expected := range expectedErrors {
+		if diagnostics[i].String() != expected {
+			t.Errorf("error [%d] wrong. Expected=%q, got=%q", i, expected, diagnostics[i])
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// reads the longest run of chars that can belong to a number, so malformed literals
+// like 1.2.3 or 0x are reported as a whole instead of being split into several tokens
+func (l *Lexer) readNumber() string {
+	initialPosition := l.position
+	hex := l.currentValue == '0' && (l.peekChar() == 'x' || l.peekChar() == 'X')
+	var previous rune
+	for {
+		switch {
+		case isNumber(l.currentValue) || isLetter(l.currentValue):
+		case l.currentValue == '.' && isNumber(l.peekChar()):
+		case (l.currentValue == '+' || l.currentValue == '-') && (previous == 'e' || previous == 'E') && !hex:
+		default:
+			return l.input[initialPosition:l.position]
+		}
+		previous = l.currentValue
+		l.readChar()
+	}
+}
+
+// validates a literal read by readNumber. Accepted forms are 0xFF, 0b1010, 0o17 and decimals
+// like 42, 1.5 or 1.5e-3, '_' may be used between two digits and decimals can't have leading zeros
+func checkNumber(literal string) error {
+	if len(literal) > 1 && literal[0] == '0' {
+		switch literal[1] {
+		case 'x', 'X':
+			return checkDigits(literal[2:], "0123456789abcdefABCDEF", "hexadecimal literal")
+		case 'b', 'B':
+			return checkDigits(literal[2:], "01", "binary literal")
+		case 'o', 'O':
+			return checkDigits(literal[2:], "01234567", "octal literal")
+		}
+	}
+
+	mantissa, exponent, hasExponent := strings.Cut(strings.ToLower(literal), "e")
+	whole, fraction, hasFraction := strings.Cut(mantissa, ".")
+	if strings.Contains(fraction, ".") {
+		return fmt.Errorf("too many decimal points")
+	}
+	if err := checkDigits(whole, "0123456789", "number"); err != nil {
+		return err
+	}
+	if len(whole) > 1 && whole[0] == '0' {
+		return fmt.Errorf("leading zeros are not allowed, use 0o for octal")
+	}
+	if hasFraction {
+		if err := checkDigits(fraction, "0123456789", "fraction"); err != nil {
+			return err
+		}
+	}
+	if hasExponent {
+		exponent = strings.TrimLeft(exponent, "+-")
+		if err := checkDigits(exponent, "0123456789", "exponent"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkDigits(digits string, valid string, kind string) error {
+	if digits == "" {
+		return fmt.Errorf("%s has no digits", kind)
+	}
+	if digits[0] == '_' || digits[len(digits)-1] == '_' || strings.Contains(digits, "__") {
+		return fmt.Errorf("'_' must be placed between digits")
+	}
+	for _, char := range digits {
+		if char != '_' && !strings.ContainsRune(valid, char) {
+			return fmt.Errorf("invalid digit %q in %s", char, kind)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package object
+
+// variables are looked up in the current scope first and then in the outer ones
+type Environment struct {
+	store     map[string]Object
+	constants map[string]bool
+	outer     *Environment
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]Object), constants: make(map[string]bool)}
+}
+
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		return e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+func (e *Environment) Set(name string, value Object) Object {
+	e.store[name] = value
+	return value
+}
+
+// binds a name that can not be reassigned later
+func (e *Environment) SetConstant(name string, value Object) Object {
+	e.store[name] = value
+	e.constants[name] = true
+	return value
+}
+
+// reports whether name is a constant declared in this scope, outer scopes are not searched
+func (e *Environment) IsLocalConstant(name string) bool {
+	return e.constants[name]
+}
+
+// reports whether name resolves to a constant in the scope where it was declared
+func (e *Environment) IsConstant(name string) bool {
+	if _, ok := e.store[name]; ok {
+		return e.constants[name]
+	}
+	if e.outer != nil {
+		return e.outer.IsConstant(name)
+	}
+	return false
+}
+
+// updates an existing variable in the scope where it was declared, returns false if it was never declared
+func (e *Environment) Assign(name string, value Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = value
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, value)
+	}
+	return false
+}
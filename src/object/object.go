@@ -0,0 +1,471 @@
+package object
+
+import (
+	"af/src/ast"
+	"af/src/diagnostic"
+	"af/src/token"
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type ObjectType string
+
+const (
+	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
+	STRING_OBJ       = "STRING"
+	BOOLEAN_OBJ      = "BOOLEAN"
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	NULL_OBJ         = "NULL"
+	FUNCTION_OBJ     = "FUNCTION"
+	BUILTIN_OBJ      = "BUILTIN"
+	STRUCT_TYPE_OBJ  = "STRUCT_TYPE"
+	STRUCT_OBJ       = "STRUCT"
+	CLASS_OBJ        = "CLASS"
+	INSTANCE_OBJ     = "INSTANCE"
+	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	ERROR_OBJ        = "ERROR"
+)
+
+// every runtime value produced by the evaluator implements Object
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType {
+	return INTEGER_OBJ
+}
+
+func (i *Integer) Inspect() string {
+	return fmt.Sprintf("%d", i.Value)
+}
+
+// integer outside the int64 range, the evaluator turns results that fit back into an Integer
+// so both forms never hold the same value. It has the INTEGER type, as scripts can't tell them apart
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (bi *BigInteger) Type() ObjectType {
+	return INTEGER_OBJ
+}
+
+func (bi *BigInteger) Inspect() string {
+	return bi.Value.String()
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// keeps the decimal point so 5.0 is not printed as an integer
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEnN") {
+		s += ".0"
+	}
+	return s
+}
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType {
+	return STRING_OBJ
+}
+
+func (s *String) Inspect() string {
+	return s.Value
+}
+
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType {
+	return BOOLEAN_OBJ
+}
+
+func (b *Boolean) Inspect() string {
+	return fmt.Sprintf("%t", b.Value)
+}
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType {
+	return ARRAY_OBJ
+}
+
+func (a *Array) Inspect() string {
+	return inspectNested(a, map[Object]bool{})
+}
+
+// strings are quoted when printed inside a collection so ["a, b"] is not confused with ["a", "b"]
+func inspectElement(obj Object, printing map[Object]bool) string {
+	if str, ok := obj.(*String); ok {
+		return strconv.Quote(str.Value)
+	}
+	return inspectNested(obj, printing)
+}
+
+// prints a container and what it holds. Index assignment and push can put a container inside
+// itself, printing holds the containers already being printed further up, meeting one again
+// prints [...] or {...} instead of recursing forever
+func inspectNested(obj Object, printing map[Object]bool) string {
+	switch obj.(type) {
+	case *Array, *Hash, *Struct, *Instance:
+		if printing[obj] {
+			if _, ok := obj.(*Array); ok {
+				return "[...]"
+			}
+			return "{...}"
+		}
+		printing[obj] = true
+		defer delete(printing, obj)
+	}
+	switch obj := obj.(type) {
+	case *Array:
+		elements := []string{}
+		for _, element := range obj.Elements {
+			elements = append(elements, inspectElement(element, printing))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *Hash:
+		pairs := []string{}
+		for _, key := range obj.Keys {
+			pair := obj.Pairs[key]
+			pairs = append(pairs, inspectElement(pair.Key, printing)+": "+inspectElement(pair.Value, printing))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	case *Struct:
+		fields := []string{}
+		for _, name := range obj.Definition.Fields {
+			fields = append(fields, name+": "+inspectElement(obj.Fields[name], printing))
+		}
+		return obj.Definition.Name + "{" + strings.Join(fields, ", ") + "}"
+	case *Instance:
+		fields := []string{}
+		for _, name := range obj.Names {
+			fields = append(fields, name+": "+inspectElement(obj.Fields[name], printing))
+		}
+		return obj.Class.Name + "{" + strings.Join(fields, ", ") + "}"
+	}
+	return obj.Inspect()
+}
+
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// implemented by the objects that can be used as dictionary keys
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// big integers get their own key type so a hashed value can't match a small integer key
+const bigIntegerKey ObjectType = "BIG_INTEGER"
+
+// a value that fits in an int64 hashes like the equal Integer
+func (bi *BigInteger) HashKey() HashKey {
+	if bi.Value.IsInt64() {
+		return (&Integer{Value: bi.Value.Int64()}).HashKey()
+	}
+	h := fnv.New64a()
+	h.Write([]byte(bi.Value.String()))
+	return HashKey{Type: bigIntegerKey, Value: h.Sum64()}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Keys keeps the insertion order so iteration and printing are deterministic
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Keys  []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+func (h *Hash) Type() ObjectType {
+	return HASH_OBJ
+}
+
+func (h *Hash) Inspect() string {
+	return inspectNested(h, map[Object]bool{})
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.Pairs[key.HashKey()]
+	if !ok {
+		return nil, false
+	}
+	return pair.Value, true
+}
+
+// adds or replaces a pair, replaced pairs keep their original position
+func (h *Hash) Set(key Object, value Object) {
+	hashKey := key.(Hashable).HashKey()
+	if _, ok := h.Pairs[hashKey]; !ok {
+		h.Keys = append(h.Keys, hashKey)
+	}
+	h.Pairs[hashKey] = HashPair{Key: key, Value: value}
+}
+
+// removes a pair keeping the order of the others, returns false if the key was missing
+func (h *Hash) Delete(key Hashable) bool {
+	hashKey := key.HashKey()
+	if _, ok := h.Pairs[hashKey]; !ok {
+		return false
+	}
+	delete(h.Pairs, hashKey)
+	h.Keys = slices.DeleteFunc(h.Keys, func(k HashKey) bool { return k == hashKey })
+	return true
+}
+
+type Null struct{}
+
+func (n *Null) Type() ObjectType {
+	return NULL_OBJ
+}
+
+func (n *Null) Inspect() string {
+	return "null"
+}
+
+// Env is the environment the function was defined in, which makes closures possible
+type Function struct {
+	Parameters []ast.Pattern
+	Body       *ast.BlockStatement
+	Env        *Environment
+	Name       string
+}
+
+func (f *Function) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+
+func (f *Function) Inspect() string {
+	params := []string{}
+	for _, param := range f.Parameters {
+		params = append(params, param.PrintAsString())
+	}
+	name := ""
+	if f.Name != "" {
+		name = " " + f.Name
+	}
+	return "fn" + name + "(" + strings.Join(params, ", ") + ") " + f.Body.PrintAsString()
+}
+
+// the definition created by a struct statement, it lists the fields every instance has
+type StructType struct {
+	Name   string
+	Fields []string
+}
+
+func (st *StructType) Type() ObjectType {
+	return STRUCT_TYPE_OBJ
+}
+
+func (st *StructType) Inspect() string {
+	return "struct " + st.Name + " { " + strings.Join(st.Fields, ", ") + " }"
+}
+
+func (st *StructType) HasField(name string) bool {
+	return slices.Contains(st.Fields, name)
+}
+
+// an instance of a StructType, Fields has a value for every field of the definition
+type Struct struct {
+	Definition *StructType
+	Fields     map[string]Object
+}
+
+func (s *Struct) Type() ObjectType {
+	return STRUCT_OBJ
+}
+
+// fields are printed in the order of the definition: Point{x: 1, y: 2}
+func (s *Struct) Inspect() string {
+	return inspectNested(s, map[Object]bool{})
+}
+
+// created by a class statement, calling it creates an instance and runs its init method
+type Class struct {
+	Name    string
+	Parent  *Class // nil when the class doesn't extend another one
+	Methods map[string]*Function
+}
+
+func (c *Class) Type() ObjectType {
+	return CLASS_OBJ
+}
+
+func (c *Class) Inspect() string {
+	if c.Parent != nil {
+		return "class " + c.Name + " extends " + c.Parent.Name
+	}
+	return "class " + c.Name
+}
+
+// looks the method up in the class and then in its ancestors,
+// returns the class that defines it too, as super calls start from its parent
+func (c *Class) FindMethod(name string) (*Function, *Class) {
+	for class := c; class != nil; class = class.Parent {
+		if method, ok := class.Methods[name]; ok {
+			return method, class
+		}
+	}
+	return nil, nil
+}
+
+// fields are added by assigning to them, usually in the init method
+type Instance struct {
+	Class  *Class
+	Fields map[string]Object
+	Names  []string // field names in the order they were added
+}
+
+func NewInstance(class *Class) *Instance {
+	return &Instance{Class: class, Fields: make(map[string]Object)}
+}
+
+func (i *Instance) Type() ObjectType {
+	return INSTANCE_OBJ
+}
+
+func (i *Instance) Inspect() string {
+	return inspectNested(i, map[Object]bool{})
+}
+
+func (i *Instance) Set(name string, value Object) {
+	if _, ok := i.Fields[name]; !ok {
+		i.Names = append(i.Names, name)
+	}
+	i.Fields[name] = value
+}
+
+// a method taken from an instance, calling it binds self to Receiver.
+// Class is the class defining Method, super inside it refers to the parent of Class
+type BoundMethod struct {
+	Receiver *Instance
+	Method   *Function
+	Class    *Class
+}
+
+func (bm *BoundMethod) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+
+func (bm *BoundMethod) Inspect() string {
+	return "method " + bm.Class.Name + "." + bm.Method.Name
+}
+
+type BuiltinFunction func(args ...Object) Object
+
+// a function implemented in Go, like the methods of strings, arrays and dictionaries
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType {
+	return BUILTIN_OBJ
+}
+
+func (b *Builtin) Inspect() string {
+	return "builtin " + b.Name
+}
+
+// wraps the value of a return statement so it can travel up through nested statements
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType {
+	return RETURN_VALUE_OBJ
+}
+
+func (rv *ReturnValue) Inspect() string {
+	return rv.Value.Inspect()
+}
+
+// signals produced by break and continue, consumed by the innermost loop
+type Break struct{}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
+type Error struct {
+	Message string
+	Code    diagnostic.Code
+	Span    token.Span // set by the evaluator to the innermost node that failed
+}
+
+func (e *Error) Type() ObjectType {
+	return ERROR_OBJ
+}
+
+func (e *Error) Inspect() string {
+	return "ERROR: " + e.Message
+}
+
+func (e *Error) Diagnostic() diagnostic.Diagnostic {
+	return diagnostic.Diagnostic{Severity: diagnostic.Error, Code: e.Code, Message: e.Message, Span: e.Span}
+}
@@ -2,33 +2,77 @@ package parser
 
 import (
 	"af/src/ast"
+	"af/src/diagnostic"
 	"af/src/lexer"
 	"af/src/token"
-	"fmt"
+	"errors"
+	"math/big"
+	"slices"
 	"strconv"
 )
 
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // = += -= *= /= %=
+	NULLISH     // ??
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUAL       // ==
 	LESSGREATER // < > <= >=
 	SUM         // + -
 	PRODUCT     // * /
 	PREFIX      // !true -5
-	CALL        // add()
+	POWER       // ** binds tighter than a prefix operator, so -2 ** 2 is -(2 ** 2)
+	CALL        // add() object.property
+	INDEX       // array[index] object?.property
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQUAL:     EQUAL,
-	token.NOT_EQUAL: EQUAL,
-	token.LT:        LESSGREATER,
-	token.GT:        LESSGREATER,
-	token.PLUS:      SUM,
-	token.MINUS:     SUM,
-	token.SLASH:     PRODUCT,
-	token.ASTERISK:  PRODUCT,
-	token.MOD:       PRODUCT,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.MOD_ASSIGN:      ASSIGN,
+	token.EQUAL:           EQUAL,
+	token.NOT_EQUAL:       EQUAL,
+	token.NULLISH:         NULLISH,
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.MOD:             PRODUCT,
+	token.POWER:           POWER,
+	token.LPAREN:          CALL,
+	token.DOT:             CALL,
+	token.LBRACKET:        INDEX,
+	token.OPTIONAL_CHAIN:  INDEX,
+}
+
+// parsing stops after this many errors, later ones are mostly noise caused by the first
+const maxErrors = 25
+
+// statements usually start with these keywords, so error recovery can resume parsing at them
+var statementKeywords = map[token.TokenType]bool{
+	token.LET:      true,
+	token.FUNCTION: true,
+	token.IF:       true,
+	token.CONST:    true,
+	token.RETURN:   true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+	token.STRUCT:   true,
+	token.CLASS:    true,
+	token.MATCH:    true,
 }
 
 type (
@@ -40,15 +84,26 @@ type Parser struct {
 	l               *lexer.Lexer
 	curToken        token.Token
 	peekToken       token.Token
-	errors          []string
+	curDoc          string // doc comments written right before curToken
+	peekDoc         string // doc comments written right before peekToken
+	diagnostics     []diagnostic.Diagnostic
 	prefixParserFns map[token.TokenType]prefixParseFN
 	infixParserFns  map[token.TokenType]infixParseFN
+	loopDepth       int  // number of enclosing loops, used to reject break and continue outside them
+	panicking       bool // set by a syntax error, later errors are ignored until the parser synchronizes
+	// { of struct declarations and of hash or struct literals that are still open, an error
+	// inside them makes synchronize skip to their } instead of stopping right before it
+	openBraces int
+	inSubclass bool // parsing the methods of a class that extends another, the only place for super
+	// names declared in each scope, the value is the declaration for constants and nil otherwise
+	scopes []map[string]*ast.LetStatement
 }
 
 func NewParser(l *lexer.Lexer) *Parser {
 	parser := &Parser{
-		l:      l,
-		errors: []string{},
+		l:           l,
+		diagnostics: []diagnostic.Diagnostic{},
+		scopes:      []map[string]*ast.LetStatement{{}},
 	}
 
 	parser.prefixParserFns = make(map[token.TokenType]prefixParseFN)
@@ -56,11 +111,20 @@ func NewParser(l *lexer.Lexer) *Parser {
 	parser.prefixParserFns[token.IDENT] = parser.parseIdentifier
 	parser.prefixParserFns[token.INT] = parser.parseInt
 	parser.prefixParserFns[token.FLOAT] = parser.parseFloat
+	parser.prefixParserFns[token.STRING] = parser.parseStringLiteral
 	parser.prefixParserFns[token.BANG] = parser.parsePrefixExpression
 	parser.prefixParserFns[token.MINUS] = parser.parsePrefixExpression
 	parser.prefixParserFns[token.TRUE] = parser.parseBoolean
 	parser.prefixParserFns[token.FALSE] = parser.parseBoolean
+	parser.prefixParserFns[token.NULL] = parser.parseNull
 	parser.prefixParserFns[token.LPAREN] = parser.parseGroupedExpression
+	parser.prefixParserFns[token.LBRACKET] = parser.parseArrayLiteral
+	parser.prefixParserFns[token.LBRACE] = parser.parseHashLiteral
+	parser.prefixParserFns[token.FUNCTION] = parser.parseFunctionLiteral
+	parser.prefixParserFns[token.IF] = parser.parseIfExpression
+	parser.prefixParserFns[token.SUPER] = parser.parseSuperExpression
+	parser.prefixParserFns[token.MATCH] = parser.parseMatchExpression
+	parser.prefixParserFns[token.ILLEGAL] = parser.parseIllegal
 
 	parser.infixParserFns[token.PLUS] = parser.parseInfixExpression
 	parser.infixParserFns[token.MINUS] = parser.parseInfixExpression
@@ -70,7 +134,23 @@ func NewParser(l *lexer.Lexer) *Parser {
 	parser.infixParserFns[token.SLASH] = parser.parseInfixExpression
 	parser.infixParserFns[token.LT] = parser.parseInfixExpression
 	parser.infixParserFns[token.GT] = parser.parseInfixExpression
+	parser.infixParserFns[token.LT_EQ] = parser.parseInfixExpression
+	parser.infixParserFns[token.GT_EQ] = parser.parseInfixExpression
+	parser.infixParserFns[token.AND] = parser.parseLogicalExpression
+	parser.infixParserFns[token.OR] = parser.parseLogicalExpression
+	parser.infixParserFns[token.NULLISH] = parser.parseLogicalExpression
+	parser.infixParserFns[token.OPTIONAL_CHAIN] = parser.parseOptionalChain
+	parser.infixParserFns[token.DOT] = parser.parseMemberExpression
 	parser.infixParserFns[token.MOD] = parser.parseInfixExpression
+	parser.infixParserFns[token.POWER] = parser.parseInfixExpression
+	parser.infixParserFns[token.LBRACKET] = parser.parseIndexExpression
+	parser.infixParserFns[token.LPAREN] = parser.parseCallExpression
+	parser.infixParserFns[token.ASSIGN] = parser.parseAssignExpression
+	parser.infixParserFns[token.PLUS_ASSIGN] = parser.parseAssignExpression
+	parser.infixParserFns[token.MINUS_ASSIGN] = parser.parseAssignExpression
+	parser.infixParserFns[token.ASTERISK_ASSIGN] = parser.parseAssignExpression
+	parser.infixParserFns[token.SLASH_ASSIGN] = parser.parseAssignExpression
+	parser.infixParserFns[token.MOD_ASSIGN] = parser.parseAssignExpression
 
 	// calling twice to set curToken and peekToken
 	parser.nextToken()
@@ -79,27 +159,102 @@ func NewParser(l *lexer.Lexer) *Parser {
 	return parser
 }
 
-func (p *Parser) GetErrors() []string {
-	return p.errors
+// errors found by the lexer and the parser, in the order they were found
+func (p *Parser) Diagnostics() []diagnostic.Diagnostic {
+	return p.diagnostics
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	emsg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, emsg)
+	p.errorAt(p.peekToken.Span, diagnostic.UnexpectedToken, "expected %s, found %s", token.Describe(t), p.peekToken.Describe())
+}
+
+func (p *Parser) errorAt(span token.Span, code diagnostic.Code, format string, a ...interface{}) {
+	p.report(diagnostic.Errorf(code, span, format, a...))
+}
+
+// records a syntax error. The rest of the statement can't be trusted,
+// so errors are ignored until synchronize skips past it
+func (p *Parser) report(d diagnostic.Diagnostic) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+	p.addDiagnostic(d)
+}
+
+// records an error in code that parsed fine, like a write to a constant, parsing goes on normally
+func (p *Parser) reportSemantic(d diagnostic.Diagnostic) {
+	p.addDiagnostic(d)
+}
+
+func (p *Parser) addDiagnostic(d diagnostic.Diagnostic) {
+	duplicate := func(other diagnostic.Diagnostic) bool {
+		return other.Code == d.Code && other.String() == d.String()
+	}
+	if p.stopped() || slices.ContainsFunc(p.diagnostics, duplicate) {
+		return
+	}
+	p.diagnostics = append(p.diagnostics, d)
+	if len(p.diagnostics) == maxErrors {
+		p.diagnostics = append(p.diagnostics, diagnostic.Errorf(diagnostic.TooManyErrors, token.Span{},
+			"too many errors, stopping after %d", maxErrors))
+	}
+}
+
+// true once the error limit was reached
+func (p *Parser) stopped() bool {
+	return len(p.diagnostics) > maxErrors
+}
+
+// panic mode recovery: skips tokens until the end of the broken statement, that is a ';',
+// or right before a '}' closing the enclosing block or a keyword starting a new statement.
+// Braces opened while skipping are skipped as a whole
+func (p *Parser) synchronize() {
+	p.panicking = false
+	depth := p.openBraces
+	p.openBraces = 0
+	for !p.curTokenIs(token.EOF) && !p.peekTokenIs(token.EOF) {
+		switch {
+		case p.curTokenIs(token.LBRACE):
+			depth++
+		case p.curTokenIs(token.RBRACE) && depth > 0:
+			depth--
+		}
+		if depth == 0 && (p.curTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) || statementKeywords[p.peekToken.Type]) {
+			return
+		}
+		p.nextToken()
+	}
 }
 
+// doc comments are not part of the grammar, they are kept aside for the declaration that follows them
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
+	p.curDoc = p.peekDoc
 	p.peekToken = p.l.NextToken()
+	p.peekDoc = ""
+	for p.peekTokenIs(token.DOC_COMMENT) {
+		if p.peekDoc != "" {
+			p.peekDoc += "\n"
+		}
+		p.peekDoc += p.peekToken.Literal
+		p.peekToken = p.l.NextToken()
+	}
+	for _, d := range p.l.TakeDiagnostics() {
+		p.addDiagnostic(d)
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 	// TODO: start parsing statements wit parser.nextToken() and append to program.Statements
-	for !p.curTokenIs(token.EOF) {
+	for !p.curTokenIs(token.EOF) && !p.stopped() {
+		// a statement with a syntax error is left out, it may be missing parts
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.panicking {
+			p.synchronize()
+		} else if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
@@ -109,31 +264,69 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case token.LET:
-		return p.parseLetStatement()
+	case token.LET, token.CONST:
+		// checked here so a failed let is a nil interface and not a nil *ast.LetStatement
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
+	case token.STRUCT:
+		return p.parseStructStatement()
+	case token.CLASS:
+		return p.parseClassStatement()
+	case token.FUNCTION:
+		if p.peekTokenIs(token.IDENT) {
+			return p.parseFunctionStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
-	if !p.expectPeek(token.IDENT) {
-		return nil
+	stmt := &ast.LetStatement{Token: p.curToken, Constant: p.curTokenIs(token.CONST), Doc: p.curDoc}
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Pattern = p.parsePattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	}
-	identifier := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	stmt.Name = identifier
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
+	p.nextToken()
 
-	// TODO: skipping the expressions until a semicolon is found
-	for !p.curTokenIs(token.SEMICOLON) {
+	stmt.Value = p.parseExpression(LOWEST)
+	// semicolon is optional at the end of a statement
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
+
+	var constant *ast.LetStatement
+	if stmt.Constant {
+		constant = stmt
+	}
+	if stmt.Pattern != nil {
+		p.declarePattern(stmt.Pattern, constant)
+	} else {
+		p.declare(stmt.Name, constant)
+	}
 	return stmt
 }
 
@@ -141,8 +334,229 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 	p.nextToken()
 
-	// TODO: skipping the expressions until a semicolon is found
-	for !p.curTokenIs(token.SEMICOLON) {
+	stmt.ReturnValue = p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseFunctionStatement() ast.Statement {
+	stmt := &ast.FunctionStatement{Token: p.curToken, Doc: p.curDoc}
+	stmt.Name = &ast.Identifier{Token: p.peekToken, Value: p.peekToken.Literal}
+	function, ok := p.parseFunctionLiteral().(*ast.FunctionLiteral)
+	if !ok {
+		return nil
+	}
+	stmt.Function = function
+	p.declare(stmt.Name, nil)
+	return stmt
+}
+
+// parses struct Point { x, y }, a trailing comma is allowed
+func (p *Parser) parseStructStatement() ast.Statement {
+	stmt := &ast.StructStatement{Token: p.curToken, Doc: p.curDoc}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	p.openBraces++
+	declared := map[string]*ast.Identifier{}
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if previous, ok := declared[field.Value]; ok {
+			p.duplicateError(diagnostic.DuplicateField, "field", field, previous, "struct "+stmt.Name.Value)
+		} else {
+			declared[field.Value] = field
+			stmt.Fields = append(stmt.Fields, field)
+		}
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	p.openBraces--
+	stmt.EndToken = p.curToken
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	p.declare(stmt.Name, nil)
+	return stmt
+}
+
+// reports a name written twice where names have to be unique, like the fields of a struct
+func (p *Parser) duplicateError(code diagnostic.Code, kind string, name, previous *ast.Identifier, in string) {
+	d := diagnostic.Errorf(code, name.Span(), "duplicate %s %s in %s", kind, name.Value, in)
+	d.Secondary = []diagnostic.Label{{Span: previous.Span(), Message: "first written here"}}
+	p.reportSemantic(d)
+}
+
+// parses class Name extends Parent { fn method() { } ... }, the extends part is optional
+func (p *Parser) parseClassStatement() ast.Statement {
+	stmt := &ast.ClassStatement{Token: p.curToken, Doc: p.curDoc}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTokenIs(token.EXTENDS) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Parent = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+	if !p.expectPeek(token.LBRACE) || !p.parseClassMethods(stmt) {
+		return nil
+	}
+	p.declare(stmt.Name, nil)
+	return stmt
+}
+
+// parses the methods up to the } closing the class body, method names are not variables
+// so they get a scope of their own
+func (p *Parser) parseClassMethods(stmt *ast.ClassStatement) bool {
+	p.openBraces++
+	outerSubclass := p.inSubclass
+	p.inSubclass = stmt.Parent != nil
+	p.pushScope()
+	defer func() {
+		p.popScope()
+		p.inSubclass = outerSubclass
+	}()
+	declared := map[string]*ast.Identifier{}
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.FUNCTION) {
+			return false
+		}
+		if !p.peekTokenIs(token.IDENT) {
+			p.peekError(token.IDENT)
+			return false
+		}
+		method, ok := p.parseFunctionStatement().(*ast.FunctionStatement)
+		if !ok {
+			return false
+		}
+		if previous, ok := declared[method.Name.Value]; ok {
+			p.duplicateError(diagnostic.DuplicateMethod, "method", method.Name, previous, "class "+stmt.Name.Value)
+		} else {
+			declared[method.Name.Value] = method.Name
+			stmt.Methods = append(stmt.Methods, method)
+		}
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+	}
+	p.nextToken()
+	p.openBraces--
+	stmt.EndToken = p.curToken
+	return true
+}
+
+// super is only valid as super.method inside the methods of a class extending another
+func (p *Parser) parseSuperExpression() ast.Expression {
+	expression := &ast.SuperExpression{Token: p.curToken}
+	if !p.inSubclass {
+		p.reportSemantic(diagnostic.Errorf(diagnostic.SuperOutsideSubclass, p.curToken.Span,
+			"super can only be used in the methods of a class that extends another"))
+	}
+	if !p.expectPeek(token.DOT) || !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	expression.Method = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return expression
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	p.pushScope()
+	stmt.Body = p.parseLoopBody()
+	p.popScope()
+	if stmt.Body == nil {
+		return nil
+	}
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Key = stmt.Value
+		stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	// the loop variables live in a scope of their own, like at runtime
+	p.pushScope()
+	if stmt.Key != nil {
+		p.declare(stmt.Key, nil)
+	}
+	p.declare(stmt.Value, nil)
+	stmt.Body = p.parseLoopBody()
+	p.popScope()
+	if stmt.Body == nil {
+		return nil
+	}
+	return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	var stmt ast.Statement
+	if p.curTokenIs(token.BREAK) {
+		stmt = &ast.BreakStatement{Token: p.curToken}
+	} else {
+		stmt = &ast.ContinueStatement{Token: p.curToken}
+	}
+	if p.loopDepth == 0 {
+		p.reportSemantic(diagnostic.Errorf(diagnostic.LoopControlOutsideLoop, p.curToken.Span,
+			"%s outside of a loop", p.curToken.Literal))
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return stmt
@@ -176,15 +590,67 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExpression
 }
 func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	identifier := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekStructLiteral() {
+		p.nextToken()
+		return p.parseStructLiteral(identifier)
+	}
+	return identifier
+}
+
+// an identifier starts a struct literal when it is followed by {} or { field:, so a
+// broken condition like if (a { b } is still reported as a missing )
+func (p *Parser) peekStructLiteral() bool {
+	if !p.peekTokenIs(token.LBRACE) {
+		return false
+	}
+	next := p.l.PeekTokens(2)
+	return next[0].Type == token.RBRACE || next[0].Type == token.IDENT && next[1].Type == token.COLON
+}
+
+// parses the { field: value, ... } part of Point{x: 1, y: 2}, a trailing comma is allowed
+func (p *Parser) parseStructLiteral(name *ast.Identifier) ast.Expression {
+	literal := &ast.StructLiteral{Token: p.curToken, Name: name, Fields: []ast.StructField{}}
+	p.openBraces++
+	written := map[string]*ast.Identifier{}
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		if previous, ok := written[field.Value]; ok {
+			p.duplicateError(diagnostic.DuplicateField, "field", field, previous, name.Value+" literal")
+		} else {
+			written[field.Value] = field
+			literal.Fields = append(literal.Fields, ast.StructField{Name: field, Value: value})
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	p.openBraces--
+	literal.EndToken = p.curToken
+	return literal
 }
 
 func (p *Parser) parseInt() ast.Expression {
 	il := &ast.IntegerLiteral{Token: p.curToken}
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return p.parseBigInt()
+	}
 	if err != nil {
-		errorMsg := fmt.Sprintf("Could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, errorMsg)
+		p.errorAt(p.curToken.Span, diagnostic.InvalidLiteral, "Could not parse %q as integer", p.curToken.Literal)
 		return nil
 	}
 
@@ -192,10 +658,30 @@ func (p *Parser) parseInt() ast.Expression {
 	return il
 }
 
+// the lexer already reported why the token is illegal, so no error is added here
+// but the statement is still broken and has to be skipped
+func (p *Parser) parseIllegal() ast.Expression {
+	p.panicking = true
+	return nil
+}
+
+func (p *Parser) parseBigInt() ast.Expression {
+	value, ok := new(big.Int).SetString(p.curToken.Literal, 0)
+	if !ok {
+		p.errorAt(p.curToken.Span, diagnostic.InvalidLiteral, "Could not parse %q as integer", p.curToken.Literal)
+		return nil
+	}
+	return &ast.BigIntegerLiteral{Token: p.curToken, Value: value}
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
+func (p *Parser) parseNull() ast.Expression {
+	return &ast.NullLiteral{Token: p.curToken}
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 	exp := p.parseExpression(LOWEST)
@@ -209,8 +695,7 @@ func (p *Parser) parseFloat() ast.Expression {
 	il := &ast.FloatLiteral{Token: p.curToken}
 	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
 	if err != nil {
-		errorMsg := fmt.Sprintf("Could not parse %q as float", p.curToken.Literal)
-		p.errors = append(p.errors, errorMsg)
+		p.errorAt(p.curToken.Span, diagnostic.InvalidLiteral, "Could not parse %q as float", p.curToken.Literal)
 		return nil
 	}
 
@@ -230,19 +715,380 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 func (p *Parser) parseInfixExpression(e ast.Expression) ast.Expression {
 	expression := &ast.InfixExpression{Token: p.curToken, Operator: p.curToken.Literal, Left: e}
 	currentPrecedence := p.curTokenPrecedence()
+	// ** is right associative, 2 ** 3 ** 2 is 2 ** (3 ** 2)
+	if expression.Operator == token.POWER {
+		currentPrecedence--
+	}
+	p.nextToken()
+	expression.Right = p.parseExpression(currentPrecedence)
+	return expression
+}
+
+func (p *Parser) parseLogicalExpression(e ast.Expression) ast.Expression {
+	expression := &ast.LogicalExpression{Token: p.curToken, Operator: p.curToken.Literal, Left: e}
+	currentPrecedence := p.curTokenPrecedence()
 	p.nextToken()
 	expression.Right = p.parseExpression(currentPrecedence)
 	return expression
 }
 
+// assignments are right associative, so a = b = 1 assigns 1 to both
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	// a syntax error in the target left parts of it nil, it was already reported
+	if p.panicking {
+		return nil
+	}
+	expression := &ast.AssignExpression{Token: p.curToken, Operator: p.curToken.Literal, Target: target}
+	switch target := target.(type) {
+	case *ast.Identifier:
+	case *ast.IndexExpression:
+		if target.Optional {
+			p.errorAt(target.Span(), diagnostic.InvalidAssignmentTarget, "invalid assignment target %s", target.PrintAsString())
+			return nil
+		}
+	case *ast.MemberExpression:
+		if target.Optional {
+			p.errorAt(target.Span(), diagnostic.InvalidAssignmentTarget, "invalid assignment target %s", target.PrintAsString())
+			return nil
+		}
+	case nil:
+		return nil
+	default:
+		p.errorAt(target.Span(), diagnostic.InvalidAssignmentTarget, "invalid assignment target %s", target.PrintAsString())
+		return nil
+	}
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+
+	if identifier, ok := target.(*ast.Identifier); ok {
+		if declaration := p.lookupConstant(identifier.Value); declaration != nil {
+			d := diagnostic.Errorf(diagnostic.AssignToConstant, expression.Span(), "cannot assign to constant %s", identifier.Value)
+			d.Secondary = []diagnostic.Label{{Span: declaredName(declaration, identifier.Value).Span(), Message: "constant declared here"}}
+			d.Help = "declare " + identifier.Value + " with let if it has to change"
+			p.reportSemantic(d)
+		}
+	}
+	return expression
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	if array.Elements == nil {
+		return nil
+	}
+	array.EndToken = p.curToken
+	return array
+}
+
+// parses comma separated expressions until the end token, a trailing comma is allowed
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+	for !p.peekTokenIs(end) {
+		p.nextToken()
+		list = append(list, p.parseExpression(LOWEST))
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(end) {
+		return nil
+	}
+	return list
+}
+
+// parses object.property, a method call like s.upper() is a call whose function is the member
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	member := &ast.MemberExpression{Token: p.curToken, Object: left}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	member.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return member
+}
+
+// parses object?.property, object?.[index] and object?.[start:end]
+func (p *Parser) parseOptionalChain(left ast.Expression) ast.Expression {
+	if p.peekTokenIs(token.LBRACKET) {
+		p.nextToken()
+		switch expression := p.parseIndexExpression(left).(type) {
+		case *ast.IndexExpression:
+			expression.Optional = true
+			return expression
+		case *ast.SliceExpression:
+			expression.Optional = true
+			return expression
+		default:
+			return nil
+		}
+	}
+
+	member := &ast.MemberExpression{Token: p.curToken, Object: left, Optional: true}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	member.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return member
+}
+
+// parses { key: value, ... }, a trailing comma is allowed
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken, Pairs: []ast.HashPair{}}
+	p.openBraces++
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	p.openBraces--
+	hash.EndToken = p.curToken
+	return hash
+}
+
+// parses both arr[index] and the slice form arr[start:end]
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+	p.nextToken()
+
+	var start ast.Expression
+	if !p.curTokenIs(token.COLON) {
+		start = p.parseExpression(LOWEST)
+		if p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			return &ast.IndexExpression{Token: tok, Left: left, Index: start, EndToken: p.curToken}
+		}
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+	}
+
+	slice := &ast.SliceExpression{Token: tok, Left: left, Start: start}
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		slice.End = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	slice.EndToken = p.curToken
+	return slice
+}
+
+// parses statements until the closing brace, curToken must be the opening brace
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.curToken, Statements: []ast.Statement{}}
+	// statements of the block recover inside it, braces opened around the block don't count
+	outerBraces := p.openBraces
+	p.openBraces = 0
+	defer func() { p.openBraces = outerBraces }()
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) {
+		if p.curTokenIs(token.EOF) {
+			d := diagnostic.Errorf(diagnostic.UnclosedBlock, p.curToken.Span, "expected `}` to close block, found %s", p.curToken.Describe())
+			d.Secondary = []diagnostic.Label{{Span: block.Token.Span, Message: "block opened here"}}
+			p.report(d)
+			return nil
+		}
+		if p.stopped() {
+			return nil
+		}
+		stmt := p.parseStatement()
+		if p.panicking {
+			p.synchronize()
+		} else if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		p.nextToken()
+	}
+	block.EndToken = p.curToken
+	return block
+}
+
+func (p *Parser) parseIfExpression() ast.Expression {
+	expression := &ast.IfExpression{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Consequence = p.parseBlockStatement()
+	if expression.Consequence == nil {
+		return nil
+	}
+
+	if !p.peekTokenIs(token.ELSE) {
+		return expression
+	}
+	p.nextToken()
+
+	// else if: the nested if becomes the only statement of the alternative block
+	if p.peekTokenIs(token.IF) {
+		elseToken := p.curToken
+		p.nextToken()
+		nested := p.parseIfExpression()
+		if nested == nil {
+			return nil
+		}
+		expression.Alternative = &ast.BlockStatement{
+			Token:      elseToken,
+			Statements: []ast.Statement{&ast.ExpressionStatement{Token: nested.(*ast.IfExpression).Token, Expression: nested}},
+			EndToken:   p.curToken,
+		}
+		return expression
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Alternative = p.parseBlockStatement()
+	if expression.Alternative == nil {
+		return nil
+	}
+	return expression
+}
+
+// parses both anonymous fn(x) { } and named fn name(x) { } functions
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	function := &ast.FunctionLiteral{Token: p.curToken}
+	if p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		function.Name = p.curToken.Literal
+	}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	function.Parameters = p.parseFunctionParameters()
+	if function.Parameters == nil {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	// loops around the function don't allow break or continue inside its body
+	outerLoopDepth := p.loopDepth
+	p.loopDepth = 0
+	p.pushScope()
+	for _, param := range function.Parameters {
+		p.declarePattern(param, nil)
+	}
+	function.Body = p.parseBlockStatement()
+	p.popScope()
+	p.loopDepth = outerLoopDepth
+	if function.Body == nil {
+		return nil
+	}
+	return function
+}
+
+// a parameter is a name, or an array or dictionary pattern destructuring the argument
+func (p *Parser) parseFunctionParameters() []ast.Pattern {
+	params := []ast.Pattern{}
+	for !p.peekTokenIs(token.RPAREN) {
+		if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+			p.nextToken()
+			param := p.parsePattern()
+			if param == nil {
+				return nil
+			}
+			params = append(params, param)
+		} else {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			params = append(params, &ast.BindingPattern{Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}})
+		}
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	return params
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	call := &ast.CallExpression{Token: p.curToken, Function: function}
+	call.Arguments = p.parseExpressionList(token.RPAREN)
+	if call.Arguments == nil {
+		return nil
+	}
+	call.EndToken = p.curToken
+	return call
+}
+
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.curToken.Span, diagnostic.ExpectedExpression, "expected an expression, found %s", p.curToken.Describe())
 }
 
 func (p *Parser) noInfixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no infix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.curToken.Span, diagnostic.UnexpectedToken, "%s can't be used as an operator", p.curToken.Describe())
+}
+
+func (p *Parser) pushScope() {
+	p.scopes = append(p.scopes, map[string]*ast.LetStatement{})
+}
+
+func (p *Parser) popScope() {
+	p.scopes = p.scopes[:len(p.scopes)-1]
+}
+
+// records a name in the innermost scope, constant is nil for names that can be reassigned.
+// Redeclaring a constant of the same scope is an error
+func (p *Parser) declare(name *ast.Identifier, constant *ast.LetStatement) {
+	scope := p.scopes[len(p.scopes)-1]
+	if previous := scope[name.Value]; previous != nil {
+		d := diagnostic.Errorf(diagnostic.RedeclaredConstant, name.Span(), "cannot redeclare constant %s", name.Value)
+		d.Secondary = []diagnostic.Label{{Span: declaredName(previous, name.Value).Span(), Message: "constant declared here"}}
+		p.reportSemantic(d)
+		return
+	}
+	scope[name.Value] = constant
+}
+
+// returns the declaration of name if the closest scope declaring it made it a constant
+func (p *Parser) lookupConstant(name string) *ast.LetStatement {
+	for i := len(p.scopes) - 1; i >= 0; i-- {
+		if constant, ok := p.scopes[i][name]; ok {
+			return constant
+		}
+	}
+	return nil
+}
+
+// the identifier declaring name in a let or const, which is inside the pattern when the value is destructured
+func declaredName(declaration *ast.LetStatement, name string) *ast.Identifier {
+	if declaration.Pattern == nil {
+		return declaration.Name
+	}
+	for _, identifier := range patternBindings(declaration.Pattern) {
+		if identifier.Value == name {
+			return identifier
+		}
+	}
+	return nil
 }
 
 // checks next token and advances one token, will be useful for handling errors
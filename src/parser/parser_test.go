@@ -2,49 +2,166 @@ package parser
 
 import (
 	"af/src/ast"
+	"af/src/diagnostic"
 	"af/src/lexer"
 	"fmt"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
 func TestLetStatements(t *testing.T) {
-	input := `
-	let x = 5;
-	let y = 10.5;
-	let foo = 15;
-	`
+	tests := []struct {
+		input              string
+		expectedIdentifier string
+		expectedValue      interface{}
+	}{
+		{"let x = 5;", "x", 5},
+		{"let y = 10.5;", "y", 10.5},
+		{"let foo = true;", "foo", true},
+		{"let bar = foo;", "bar", "foo"},
+		{"let baz = 15", "baz", 15},
+	}
 
-	l := lexer.NewLexer(input)
-	p := NewParser(l)
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
 
-	program := p.ParseProgram()
-	checkParserErrors(t, p)
+		if program == nil {
+			t.Fatalf("ParseProgram() returned nill")
+		}
 
-	if program == nil {
-		t.Fatalf("ParseProgram() returned nill")
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not have 1 statement, got %d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0]
+		if !testLetStatements(t, stmt, tt.expectedIdentifier) {
+			return
+		}
+
+		value := stmt.(*ast.LetStatement).Value
+		if !testLiteralExpression(t, value, tt.expectedValue) {
+			return
+		}
 	}
+}
 
-	if len(program.Statements) != 3 {
-		t.Fatalf("program.Statements does not have 3 statementes, got %d", len(program.Statements))
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		names    []string
+	}{
+		{"let [a, b, ...rest] = arr;", "let [a, b, ...rest] = arr;", []string{"a", "b", "rest"}},
+		{"let {name, age: years} = person", `let {"name": name, "age": years} = person;`, []string{"name", "years"}},
+		{`let [first, {tags: [tag, ..._]}] = items`, `let [first, {"tags": [tag, ..._]}] = items;`, []string{"first", "tag"}},
+		{"const [x, 0, _] = point", "const [x, 0, _] = point;", []string{"x"}},
+		{"let [] = empty", "let [] = empty;", []string{}},
 	}
 
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Name != nil || stmt.Pattern == nil {
+			t.Fatalf("expected a pattern and no name. got Name=%v, Pattern=%v", stmt.Name, stmt.Pattern)
+		}
+		if program.PrintAsString() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.PrintAsString())
+		}
+		names := []string{}
+		for _, name := range patternBindings(stmt.Pattern) {
+			names = append(names, name.Value)
+		}
+		if !reflect.DeepEqual(names, tt.names) {
+			t.Errorf("wrong names bound by %q. expected=%v, got=%v", tt.input, tt.names, names)
+		}
+	}
+}
+
+func TestDestructuringErrors(t *testing.T) {
 	tests := []struct {
-		expectedIdentifier string
+		input    string
+		expected string
 	}{
-		{expectedIdentifier: "x"},
-		{expectedIdentifier: "y"},
-		{expectedIdentifier: "foo"},
+		{"let [a, a] = pair", "1:9: duplicate binding a in pattern"},
+		{"let {a, b: [a]} = pair", "1:13: duplicate binding a in pattern"},
+		{"let 5 = x", "1:5: expected identifier, found integer `5`"},
+		{"let [a, +] = x", "1:9: expected a pattern, found `+`"},
+		{"let [a, b] x", "1:12: expected `=`, found identifier `x`"},
+		{"fn f([a, a]) { a }", "1:10: duplicate binding a in pattern"},
+		{"fn f({a}, 1) { a }", "1:11: expected identifier, found integer `1`"},
+		{"const [a, b] = pair; b = 1", "1:22: cannot assign to constant b"},
+		{"const {a} = pair; let a = 1", "1:23: cannot redeclare constant a"},
+		{"fn f([a, b], {c}) { a = b + c }", ""},
+		{"let [a, b] = pair; a = b", ""},
 	}
-	for i, test := range tests {
-		stmt := program.Statements[i]
-		if !testLetStatements(t, stmt, test.expectedIdentifier) {
-			return
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+		errors := errorStrings(p)
+		if tt.expected == "" {
+			if len(errors) != 0 {
+				t.Errorf("expected no errors for %q, got=%q", tt.input, errors)
+			}
+			continue
+		}
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
 		}
 	}
 }
 
+func TestDestructuredConstantDeclaration(t *testing.T) {
+	input := "const [a, {b}] = pair\nb = 1"
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	p.ParseProgram()
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected one error, got=%q", errorStrings(p))
+	}
+	declaration := diagnostics[0].Secondary
+	if len(declaration) != 1 || declaration[0].Span.String() != "1:12-1:13" {
+		t.Errorf("expected the name in the pattern as secondary span, got=%v", declaration)
+	}
+}
+
+func TestStatementsWithoutSemicolon(t *testing.T) {
+	input := `
+	let x = 5
+	let y = x + 1
+	return y`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("program.Statements does not have 3 statementes, got %d", len(program.Statements))
+	}
+
+	expected := "let x = 5;let y = (x + 1);return y;"
+	if program.PrintAsString() != expected {
+		t.Errorf("expected=%q, got=%q", expected, program.PrintAsString())
+	}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
-	errors := p.GetErrors()
+	errors := p.Diagnostics()
 	if len(errors) == 0 {
 		return
 	}
@@ -55,6 +172,15 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	}
 	t.FailNow()
 }
+
+// the short line:column: message form of the parser diagnostics
+func errorStrings(p *Parser) []string {
+	errors := []string{}
+	for _, d := range p.Diagnostics() {
+		errors = append(errors, d.String())
+	}
+	return errors
+}
 func testLetStatements(t *testing.T, s ast.Statement, expected string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("Expected 'let', got %q", s.TokenLiteral())
@@ -78,35 +204,41 @@ func testLetStatements(t *testing.T, s ast.Statement, expected string) bool {
 }
 
 func TestReturnStatements(t *testing.T) {
-	input := `
-	return 5;
-	return 10.5;
-	return "hello";
-	`
-	lexer := lexer.NewLexer(input)
-	parser := NewParser(lexer)
+	tests := []struct {
+		input         string
+		expectedValue interface{}
+	}{
+		{"return 5;", 5},
+		{"return 10.5;", 10.5},
+		{"return hello;", "hello"},
+		{"return false", false},
+	}
 
-	program := parser.ParseProgram()
-	checkParserErrors(t, parser)
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
 
-	if program == nil {
-		t.Fatalf("ParseProgram() returned nill")
-	}
+		if program == nil {
+			t.Fatalf("ParseProgram() returned nill")
+		}
 
-	if len(program.Statements) != 3 {
-		t.Fatalf("program.Statements does not have 3 statementes, got %d", len(program.Statements))
-	}
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not have 1 statement, got %d", len(program.Statements))
+		}
 
-	for _, stmt := range program.Statements {
-		returnStmt, ok := stmt.(*ast.ReturnStatement)
+		returnStmt, ok := program.Statements[0].(*ast.ReturnStatement)
 		if !ok {
-			t.Errorf("stmt not *ast.ReturnStatement. got=%T", stmt)
-			continue
+			t.Fatalf("stmt not *ast.ReturnStatement. got=%T", program.Statements[0])
 		}
 		if returnStmt.TokenLiteral() != "return" {
 			t.Errorf("returnStmt.TokenLiteral not 'return', got %q",
 				returnStmt.TokenLiteral())
 		}
+		if !testLiteralExpression(t, returnStmt.ReturnValue, tt.expectedValue) {
+			return
+		}
 	}
 }
 
@@ -350,10 +482,13 @@ func TestParseInfixExpressions(t *testing.T) {
 		{"5 - 5;", 5, "-", 5},
 		{"5 * 5;", 5, "*", 5},
 		{"5 / 5;", 5, "/", 5},
+		{"5 % 5;", 5, "%", 5},
 		{"5 > 5;", 5, ">", 5},
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
@@ -409,37 +544,29 @@ func testFloatLiteral(t *testing.T, il ast.Expression, value float64) bool {
 	return true
 }
 
-// TODO: print program statements as text
-// requires: parsing expression
 func TestPrintAsStringStatements(t *testing.T) {
-	input := `
-	return 5;
-	return 10.5;
-	return "hello";
-	`
-	lexer := lexer.NewLexer(input)
-	parser := NewParser(lexer)
-
-	program := parser.ParseProgram()
-	checkParserErrors(t, parser)
-
-	if program == nil {
-		t.Fatalf("ParseProgram() returned nill")
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"return 5;", "return 5;"},
+		{"return 10.5", "return 10.5;"},
+		{"return a + b * c;", "return (a + (b * c));"},
+		{"let x = 5;", "let x = 5;"},
+		{"let y = -x == 10", "let y = ((-x) == 10);"},
+		{`return "hello";`, `return "hello";`},
+		{`let s = "Hi ${name}!"`, `let s = ("Hi " + name + "!");`},
 	}
 
-	if len(program.Statements) != 3 {
-		t.Fatalf("program.Statements does not have 3 statementes, got %d", len(program.Statements))
-	}
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
 
-	for _, stmt := range program.Statements {
-		returnStmt, ok := stmt.(*ast.ReturnStatement)
-		if !ok {
-			t.Errorf("stmt not *ast.ReturnStatement. got=%T", stmt)
-			continue
-		}
-		if returnStmt.TokenLiteral() != "return" {
-			t.Errorf("returnStmt.TokenLiteral not 'return', got %q",
-				returnStmt.TokenLiteral())
+		actual := program.PrintAsString()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
 		}
 	}
 }
@@ -549,6 +676,10 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"a + b / c",
 			"(a + (b / c))",
 		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
 		{
 			"a + b * c + d / e - f",
 			"(((a + (b * c)) + (d / e)) - f)",
@@ -589,6 +720,118 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"!(true == true)",
 			"(!(true == true))",
 		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a == b && c != d",
+			"((a == b) && (c != d))",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"!a || b",
+			"((!a) || b)",
+		},
+		{
+			"a = b = c + 1",
+			"(a = (b = (c + 1)))",
+		},
+		{
+			"a += b * 2 || c",
+			"(a += ((b * 2) || c))",
+		},
+		{
+			"arr[i + 1] -= 1",
+			"((arr[(i + 1)]) -= 1)",
+		},
+		{
+			"a ?? b || c",
+			"(a ?? (b || c))",
+		},
+		{
+			"x = a ?? b ?? c",
+			"(x = ((a ?? b) ?? c))",
+		},
+		{
+			"a?.b?.[0] + c?.[1:]",
+			"(((a?.b)?.[0]) + (c?.[1:]))",
+		},
+		{
+			"-a?.b",
+			"(-(a?.b))",
+		},
+		{
+			"a?.f(1)",
+			"(a?.f)(1)",
+		},
+		{
+			"a.b.c + d.e * f",
+			"(((a.b).c) + ((d.e) * f))",
+		},
+		{
+			"-s.upper() + arr.map(f)[0]",
+			"((-(s.upper)()) + ((arr.map)(f)[0]))",
+		},
+		{
+			"2 ** a.b",
+			"(2 ** (a.b))",
+		},
+		{
+			"p.x = Point{x: 1, y: a + b}.x * 2",
+			"((p.x) = ((Point{x: 1, y: (a + b)}.x) * 2))",
+		},
+		{
+			"p == Point{}",
+			"(p == Point{})",
+		},
+		{
+			"a + add(b * c) + d",
+			"((a + add((b * c))) + d)",
+		},
+		{
+			"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))",
+			"add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))",
+		},
+		{
+			"add(a + b + c * d / f + g)",
+			"add((((a + b) + ((c * d) / f)) + g))",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"-a[0]",
+			"(-(a[0]))",
+		},
+		{
+			"a[1:2][0] + b[:-1] + c[1:]",
+			"((((a[1:2])[0]) + (b[:(-1)])) + (c[1:]))",
+		},
+		{
+			"2 ** 3 ** 2",
+			"(2 ** (3 ** 2))",
+		},
+		{
+			"-2 ** 2 * 3",
+			"((-(2 ** 2)) * 3)",
+		},
+		{
+			"2 ** -a[0]",
+			"(2 ** (-(a[0])))",
+		},
 	}
 	for _, tt := range tests {
 		l := lexer.NewLexer(tt.input)
@@ -601,3 +844,1578 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello\tworld \u{41}";`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != "hello\tworld A" {
+		t.Errorf("literal.Value not %q. got=%q", "hello\tworld A", literal.Value)
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	input := `"sum: ${a + 1}, name: ${"x${b}"}";`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	concat, ok := stmt.Expression.(*ast.ConcatExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.ConcatExpression. got=%T", stmt.Expression)
+	}
+	if len(concat.Parts) != 4 {
+		t.Fatalf("concat.Parts does not have 4 parts, got %d", len(concat.Parts))
+	}
+	if !testInfixExpression(t, concat.Parts[1], "a", "+", 1) {
+		return
+	}
+	expected := `("sum: " + (a + 1) + ", name: " + ("x" + b))`
+	if concat.PrintAsString() != expected {
+		t.Errorf("expected=%q, got=%q", expected, concat.PrintAsString())
+	}
+}
+
+func TestInterpolationWithNestedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"${ f("}") }"`, `(f("}"))`},
+		{`"${ "${x}" }"`, `((x))`},
+		{`"${ "{" + "\"}" }"`, `(("{" + "\"}"))`},
+		{`"${ "a${ "}" }b" }!"`, `(("a" + "}" + "b") + "!")`},
+		{`"${ {"k": "}"}["k"] }"`, `(({"k": "}"}["k"]))`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.PrintAsString() != tt.expected {
+			t.Errorf("wrong parse of %s. expected=%q, got=%q", tt.input, tt.expected, program.PrintAsString())
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []string{
+		`"bad \q escape"`,
+		`"empty ${}"`,
+		`"two ${a b}"`,
+		`"broken ${1 +}"`,
+	}
+
+	for _, input := range tests {
+		l := lexer.NewLexer(input)
+		p := NewParser(l)
+		p.ParseProgram()
+		if len(p.Diagnostics()) == 0 {
+			t.Errorf("expected parser errors for %s", input)
+		}
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingEmptyArrayLiterals(t *testing.T) {
+	input := "[]"
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+	if len(array.Elements) != 0 {
+		t.Fatalf("len(array.Elements) not 0. got=%d", len(array.Elements))
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input string
+		start interface{}
+		end   interface{}
+	}{
+		{"arr[1:3]", 1, 3},
+		{"arr[:3]", nil, 3},
+		{"arr[1:]", 1, nil},
+		{"arr[:]", nil, nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		slice, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+		if !testIdentifier(t, slice.Left, "arr") {
+			return
+		}
+		if tt.start == nil && slice.Start != nil {
+			t.Errorf("slice.Start not nil. got=%s", slice.Start.PrintAsString())
+		} else if tt.start != nil && !testLiteralExpression(t, slice.Start, tt.start) {
+			return
+		}
+		if tt.end == nil && slice.End != nil {
+			t.Errorf("slice.End not nil. got=%s", slice.End.PrintAsString())
+		} else if tt.end != nil && !testLiteralExpression(t, slice.End, tt.end) {
+			return
+		}
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3,}`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
+	}
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
+		}
+		if literal.Value != expected[i].key {
+			t.Errorf("key is not %q. got=%q", expected[i].key, literal.Value)
+		}
+		testIntegerLiteral(t, pair.Value, expected[i].value)
+	}
+}
+
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	input := "{}"
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+	if len(hash.Pairs) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+}
+
+func TestParsingHashLiteralsWithExpressions(t *testing.T) {
+	input := `let d = {
+		"one": 0 + 1,
+		two: 10 - 8,
+		1 + 2: 15 / 5
+	}`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := `let d = {"one": (0 + 1), two: (10 - 8), (1 + 2): (15 / 5)};`
+	if program.PrintAsString() != expected {
+		t.Errorf("expected=%q, got=%q", expected, program.PrintAsString())
+	}
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+	input := `fn(x, y) { x + y; }`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+	}
+	if len(function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got=%d", len(function.Parameters))
+	}
+	testLiteralExpression(t, function.Parameters[0].(*ast.BindingPattern).Name, "x")
+	testLiteralExpression(t, function.Parameters[1].(*ast.BindingPattern).Name, "y")
+
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("function.Body.Statements has not 1 statements. got=%d", len(function.Body.Statements))
+	}
+	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("function body stmt is not ast.ExpressionStatement. got=%T", function.Body.Statements[0])
+	}
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestFunctionParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{input: "fn() {};", expectedParams: []string{}},
+		{input: "fn(x) {};", expectedParams: []string{"x"}},
+		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Errorf("length parameters wrong. want %d, got=%d", len(tt.expectedParams), len(function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i].(*ast.BindingPattern).Name, ident)
+		}
+	}
+}
+
+func TestFunctionPatternParameters(t *testing.T) {
+	input := "fn([a, ...rest], {name, age: years}, last) { a }"
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	expected := []string{"*ast.ArrayPattern", "*ast.HashPattern", "*ast.BindingPattern"}
+	if len(function.Parameters) != len(expected) {
+		t.Fatalf("wrong number of parameters. want %d, got=%d", len(expected), len(function.Parameters))
+	}
+	for i, kind := range expected {
+		if got := fmt.Sprintf("%T", function.Parameters[i]); got != kind {
+			t.Errorf("parameter %d: expected %s, got %s", i, kind, got)
+		}
+	}
+	printed := `fn([a, ...rest], {"name": name, "age": years}, last) { a }`
+	if function.PrintAsString() != printed {
+		t.Errorf("expected=%q, got=%q", printed, function.PrintAsString())
+	}
+}
+
+func TestFunctionStatementParsing(t *testing.T) {
+	input := `fn getGitHubUrl (){
+		return "https://github.com/AnderFernandezCE"
+	}
+	getGitHubUrl()`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.FunctionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.FunctionStatement. got=%T", program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Name, "getGitHubUrl") {
+		return
+	}
+	if stmt.Function.Name != "getGitHubUrl" {
+		t.Errorf("stmt.Function.Name not %q. got=%q", "getGitHubUrl", stmt.Function.Name)
+	}
+	if len(stmt.Function.Parameters) != 0 {
+		t.Errorf("stmt.Function.Parameters not empty. got=%d", len(stmt.Function.Parameters))
+	}
+	expected := `fn getGitHubUrl() { return "https://github.com/AnderFernandezCE"; }getGitHubUrl()`
+	if program.PrintAsString() != expected {
+		t.Errorf("expected=%q, got=%q", expected, program.PrintAsString())
+	}
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Function, "add") {
+		return
+	}
+	if len(exp.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
+	}
+	testLiteralExpression(t, exp.Arguments[0], 1)
+	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
+	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
+}
+
+func TestUnterminatedBlock(t *testing.T) {
+	input := "fn(x) { x + 1"
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	p.ParseProgram()
+	if len(p.Diagnostics()) == 0 {
+		t.Fatalf("expected parser errors for unterminated block")
+	}
+}
+
+func TestIfExpression(t *testing.T) {
+	input := `if (x < y) { x }`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+	if len(exp.Consequence.Statements) != 1 {
+		t.Errorf("consequence is not 1 statements. got=%d", len(exp.Consequence.Statements))
+	}
+	consequence, ok := exp.Consequence.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T", exp.Consequence.Statements[0])
+	}
+	if !testIdentifier(t, consequence.Expression, "x") {
+		return
+	}
+	if exp.Alternative != nil {
+		t.Errorf("exp.Alternative was not nil. got=%+v", exp.Alternative)
+	}
+}
+
+func TestIfElseExpression(t *testing.T) {
+	input := `if (x < y) { x } else { y }`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+	if exp.Alternative == nil || len(exp.Alternative.Statements) != 1 {
+		t.Fatalf("exp.Alternative does not have 1 statement. got=%+v", exp.Alternative)
+	}
+	alternative, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T", exp.Alternative.Statements[0])
+	}
+	if !testIdentifier(t, alternative.Expression, "y") {
+		return
+	}
+}
+
+func TestElseIfChain(t *testing.T) {
+	input := `let x = if (a) { 1 } else if (b) { 2 } else { 3 }`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	exp, ok := let.Value.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("let.Value is not ast.IfExpression. got=%T", let.Value)
+	}
+	nestedStmt, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Alternative.Statements[0] is not ast.ExpressionStatement. got=%T", exp.Alternative.Statements[0])
+	}
+	nested, ok := nestedStmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("else if is not ast.IfExpression. got=%T", nestedStmt.Expression)
+	}
+	if !testIdentifier(t, nested.Condition, "b") {
+		return
+	}
+	if nested.Alternative == nil {
+		t.Fatalf("nested.Alternative is nil")
+	}
+
+	expected := "let x = if a { 1 } else { if b { 2 } else { 3 } };"
+	if program.PrintAsString() != expected {
+		t.Errorf("expected=%q, got=%q", expected, program.PrintAsString())
+	}
+}
+
+func TestLogicalExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		left     interface{}
+		operator string
+		right    interface{}
+	}{
+		{"a && b", "a", "&&", "b"},
+		{"true || false", true, "||", false},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.LogicalExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.LogicalExpression. got=%T", stmt.Expression)
+		}
+		if !testLiteralExpression(t, exp.Left, tt.left) {
+			return
+		}
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator is not '%s'. got=%q", tt.operator, exp.Operator)
+		}
+		if !testLiteralExpression(t, exp.Right, tt.right) {
+			return
+		}
+	}
+}
+
+func TestWhileStatementParsing(t *testing.T) {
+	input := `while (x < 10) { x; break; }`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T", program.Statements[0])
+	}
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("stmt.Body.Statements has not 2 statements. got=%d", len(stmt.Body.Statements))
+	}
+	if _, ok := stmt.Body.Statements[1].(*ast.BreakStatement); !ok {
+		t.Errorf("stmt.Body.Statements[1] is not ast.BreakStatement. got=%T", stmt.Body.Statements[1])
+	}
+}
+
+func TestForStatementParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		key      string
+		value    string
+		expected string
+	}{
+		{"for (x in arr) { continue }", "", "x", "for (x in arr) { continue; }"},
+		{"for (k, v in {\"a\": 1}) { k }", "k", "v", "for (k, v in {\"a\": 1}) { k }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+		}
+		if tt.key == "" && stmt.Key != nil {
+			t.Errorf("stmt.Key is not nil. got=%s", stmt.Key.PrintAsString())
+		} else if tt.key != "" && !testIdentifier(t, stmt.Key, tt.key) {
+			return
+		}
+		if !testIdentifier(t, stmt.Value, tt.value) {
+			return
+		}
+		if program.PrintAsString() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.PrintAsString())
+		}
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"break;", "1:1: break outside of a loop"},
+		{"if (true) { continue }", "1:13: continue outside of a loop"},
+		{"while (true) { fn() { break } }", "1:23: break outside of a loop"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+		errors := errorStrings(p)
+		if len(errors) != 1 || errors[0] != tt.expectedError {
+			t.Errorf("expected error %q, got=%q", tt.expectedError, errors)
+		}
+	}
+}
+
+func TestAssignExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		target   string
+		operator string
+		value    interface{}
+	}{
+		{"x = 5;", "x", "=", 5},
+		{"x += 1", "x", "+=", 1},
+		{"x -= y", "x", "-=", "y"},
+		{"x *= 2.5", "x", "*=", 2.5},
+		{"x /= 2", "x", "/=", 2},
+		{"x %= 2", "x", "%=", 2},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T", stmt.Expression)
+		}
+		if !testIdentifier(t, exp.Target, tt.target) {
+			return
+		}
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator is not '%s'. got=%q", tt.operator, exp.Operator)
+		}
+		if !testLiteralExpression(t, exp.Value, tt.value) {
+			return
+		}
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	tests := []string{
+		"1 = 2",
+		"a + b = c",
+		"f() = 1",
+		"arr[1:2] = [1]",
+		"(1 + ) = 2",
+		"- else = [",
+		"a?.[ = 1",
+		"a?.[1:] ?.b = 1",
+		"a?. = 1",
+		"a?.[1 = 2",
+	}
+
+	for _, input := range tests {
+		l := lexer.NewLexer(input)
+		p := NewParser(l)
+		p.ParseProgram()
+		if len(p.Diagnostics()) == 0 {
+			t.Errorf("expected parser errors for %s", input)
+		}
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	input := `const url = "https://github.com/AnderFernandezCE"; let x = 1`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+	constStmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T", program.Statements[0])
+	}
+	if !constStmt.Constant || constStmt.TokenLiteral() != "const" {
+		t.Errorf("constStmt is not a constant. got=%s", constStmt.PrintAsString())
+	}
+	if letStmt := program.Statements[1].(*ast.LetStatement); letStmt.Constant {
+		t.Errorf("letStmt is a constant. got=%s", letStmt.PrintAsString())
+	}
+
+	expected := `const url = "https://github.com/AnderFernandezCE";let x = 1;`
+	if program.PrintAsString() != expected {
+		t.Errorf("expected=%q, got=%q", expected, program.PrintAsString())
+	}
+}
+
+func TestConstAssignmentErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{
+			"const x = 1; x = 2",
+			"1:14: cannot assign to constant x",
+		},
+		{
+			"const x = 1; x += 2",
+			"1:14: cannot assign to constant x",
+		},
+		{
+			"const x = 1;\nfn f() {\n  x = 2\n}",
+			"3:3: cannot assign to constant x",
+		},
+		{
+			"const x = 1; while (true) { x = 2 }",
+			"1:29: cannot assign to constant x",
+		},
+		{
+			"const x = 1; let x = 2",
+			"1:18: cannot redeclare constant x",
+		},
+		{
+			"const x = 1; \"${x = 2}\"",
+			"1:17: cannot assign to constant x",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+		errors := errorStrings(p)
+		if len(errors) != 1 || errors[0] != tt.expectedError {
+			t.Errorf("expected error %q, got=%q", tt.expectedError, errors)
+			continue
+		}
+		declaration := p.Diagnostics()[0].Secondary
+		if len(declaration) != 1 || declaration[0].Span.String() != "1:7-1:8" || declaration[0].Message != "constant declared here" {
+			t.Errorf("expected the declaration as secondary span, got=%v", declaration)
+		}
+	}
+}
+
+func TestConstShadowing(t *testing.T) {
+	tests := []string{
+		"const x = 1; fn f() { let x = 2; x = 3 }",
+		"const x = 1; fn f(x) { x = 3 }",
+		"const x = 1; for (x in [1]) { x = 3 }",
+		"const x = 1; while (true) { let x = 2; x = 3 }",
+		"while (true) { const c = 1 } let c = 2",
+		"let x = 1; x = 2",
+		"const x = 1; let y = 2; y = x",
+	}
+
+	for _, input := range tests {
+		l := lexer.NewLexer(input)
+		p := NewParser(l)
+		p.ParseProgram()
+		checkParserErrors(t, p)
+	}
+}
+
+func TestNullLiteralParsing(t *testing.T) {
+	input := "null"
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	if _, ok := stmt.Expression.(*ast.NullLiteral); !ok {
+		t.Fatalf("stmt.Expression is not ast.NullLiteral. got=%T", stmt.Expression)
+	}
+}
+
+func TestOptionalChainParsing(t *testing.T) {
+	input := "config?.name"
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	member, ok := stmt.Expression.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MemberExpression. got=%T", stmt.Expression)
+	}
+	if !member.Optional {
+		t.Errorf("member.Optional is false")
+	}
+	if !testIdentifier(t, member.Object, "config") {
+		return
+	}
+	if !testIdentifier(t, member.Property, "name") {
+		return
+	}
+}
+
+func TestMemberExpressionParsing(t *testing.T) {
+	input := "list.push(1, x)"
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+	member, ok := call.Function.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("call.Function is not ast.MemberExpression. got=%T", call.Function)
+	}
+	if member.Optional {
+		t.Errorf("member.Optional is true")
+	}
+	if !testIdentifier(t, member.Object, "list") {
+		return
+	}
+	if !testIdentifier(t, member.Property, "push") {
+		return
+	}
+	if len(call.Arguments) != 2 {
+		t.Fatalf("wrong number of arguments. got=%d", len(call.Arguments))
+	}
+	testLiteralExpression(t, call.Arguments[0], 1)
+	testLiteralExpression(t, call.Arguments[1], "x")
+}
+
+func TestMemberExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a.1", "1:3: expected identifier, found integer `1`"},
+		{"a.", "1:3: expected identifier, found end of input"},
+		{"a.(b)", "1:3: expected identifier, found `(`"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+		errors := errorStrings(p)
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestStructStatementParsing(t *testing.T) {
+	input := `
+/// A point in the plane.
+struct Point { x, y, }
+struct Empty {}`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.StructStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.StructStatement. got=%T", program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Name, "Point") {
+		return
+	}
+	if len(stmt.Fields) != 2 {
+		t.Fatalf("wrong number of fields. got=%d", len(stmt.Fields))
+	}
+	testIdentifier(t, stmt.Fields[0], "x")
+	testIdentifier(t, stmt.Fields[1], "y")
+	if stmt.Doc != "A point in the plane." {
+		t.Errorf("wrong doc. got=%q", stmt.Doc)
+	}
+	if stmt.Span().String() != "3:1-3:23" {
+		t.Errorf("wrong span. got=%s", stmt.Span())
+	}
+	if program.PrintAsString() != "struct Point { x, y }struct Empty {  }" {
+		t.Errorf("wrong string. got=%q", program.PrintAsString())
+	}
+}
+
+func TestStructLiteralParsing(t *testing.T) {
+	input := `Point{x: 1, y: "a",}`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StructLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.StructLiteral. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, literal.Name, "Point") {
+		return
+	}
+	if len(literal.Fields) != 2 {
+		t.Fatalf("wrong number of fields. got=%d", len(literal.Fields))
+	}
+	testIdentifier(t, literal.Fields[0].Name, "x")
+	testLiteralExpression(t, literal.Fields[0].Value, 1)
+	testIdentifier(t, literal.Fields[1].Name, "y")
+	if literal.Span().String() != "1:1-1:21" {
+		t.Errorf("wrong span. got=%s", literal.Span())
+	}
+}
+
+func TestStructErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, y, x }", "1:22: duplicate field x in struct Point"},
+		{"Point{x: 1, x: 2}", "1:13: duplicate field x in Point literal"},
+		{"struct { x }", "1:8: expected identifier, found `{`"},
+		{"struct Point { x y }", "1:18: expected `}`, found identifier `y`"},
+		{"struct Point { 1 }", "1:16: expected identifier, found integer `1`"},
+		{"Point{x: 1 y: 2}", "1:12: expected `}`, found identifier `y`"},
+		{"if (a { b }", "1:7: expected `)`, found `{`"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+		errors := errorStrings(p)
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestClassStatementParsing(t *testing.T) {
+	input := `
+/// Counts things.
+class Counter extends Base {
+	fn init(start) { self.count = start }
+	/// Adds one.
+	fn increment() { super.increment(); self.count += 1 };
+}`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ClassStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ClassStatement. got=%T", program.Statements[0])
+	}
+	testIdentifier(t, stmt.Name, "Counter")
+	testIdentifier(t, stmt.Parent, "Base")
+	if stmt.Doc != "Counts things." {
+		t.Errorf("wrong doc. got=%q", stmt.Doc)
+	}
+	if len(stmt.Methods) != 2 {
+		t.Fatalf("wrong number of methods. got=%d", len(stmt.Methods))
+	}
+	testIdentifier(t, stmt.Methods[0].Name, "init")
+	testIdentifier(t, stmt.Methods[1].Name, "increment")
+	if stmt.Methods[1].Doc != "Adds one." {
+		t.Errorf("wrong method doc. got=%q", stmt.Methods[1].Doc)
+	}
+
+	expected := "class Counter extends Base { fn init(start) { ((self.count) = start) } " +
+		"fn increment() { super.increment()((self.count) += 1) } }"
+	if program.PrintAsString() != expected {
+		t.Errorf("wrong string.\nexpected=%q\ngot=%q", expected, program.PrintAsString())
+	}
+}
+
+func TestClassErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"class A { fn f() {} fn f() {} }", "1:24: duplicate method f in class A"},
+		{"class A { let x = 1 }", "1:11: expected `fn`, found `let`"},
+		{"class A { fn () {} }", "1:14: expected identifier, found `(`"},
+		{"class A extends { }", "1:17: expected identifier, found `{`"},
+		{"class A { fn f() { super.f() } }", "1:20: super can only be used in the methods of a class that extends another"},
+		{"fn f() { super.f() }", "1:10: super can only be used in the methods of a class that extends another"},
+		{"class A extends B { fn f() { class C { fn g() { super.g() } } } }", "1:49: super can only be used in the methods of a class that extends another"},
+		{"class A extends B { fn f() { super } }", "1:36: expected `.`, found `}`"},
+		{"class A extends B { fn f() { super.1 } }", "1:36: expected identifier, found integer `1`"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+		errors := errorStrings(p)
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestMatchExpressionParsing(t *testing.T) {
+	input := `match (value) {
+	0 => "zero",
+	-1.5 | 2 => 1,
+}`
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	p.ParseProgram()
+	if len(p.Diagnostics()) == 0 {
+		t.Fatalf("expected an error for the | between patterns")
+	}
+
+	input = `match (value) {
+	0 => "zero",
+	-1.5 => "negative",
+	n if n > 10 => "big",
+	[first, ...rest] => first,
+	{"id": 1, name, age: [_, years]} => name,
+	null => null,
+	_ => "other",
+}`
+	l = lexer.NewLexer(input)
+	p = NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	match, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MatchExpression. got=%T", stmt.Expression)
+	}
+	testIdentifier(t, match.Value, "value")
+	if len(match.Arms) != 7 {
+		t.Fatalf("wrong number of arms. got=%d", len(match.Arms))
+	}
+
+	patterns := []struct {
+		kind     string
+		expected string
+	}{
+		{"*ast.ValuePattern", "0"},
+		{"*ast.ValuePattern", "(-1.5)"},
+		{"*ast.BindingPattern", "n"},
+		{"*ast.ArrayPattern", "[first, ...rest]"},
+		{"*ast.HashPattern", `{"id": 1, "name": name, "age": [_, years]}`},
+		{"*ast.ValuePattern", "null"},
+		{"*ast.WildcardPattern", "_"},
+	}
+	for i, tt := range patterns {
+		pattern := match.Arms[i].Pattern
+		if kind := fmt.Sprintf("%T", pattern); kind != tt.kind {
+			t.Errorf("arm %d: wrong pattern type. expected=%s, got=%s", i, tt.kind, kind)
+		}
+		if pattern.PrintAsString() != tt.expected {
+			t.Errorf("arm %d: wrong pattern. expected=%q, got=%q", i, tt.expected, pattern.PrintAsString())
+		}
+	}
+	if match.Arms[2].Guard == nil || match.Arms[2].Guard.PrintAsString() != "(n > 10)" {
+		t.Errorf("wrong guard for arm 2")
+	}
+	if match.Span().String() != "1:1-9:2" {
+		t.Errorf("wrong span. got=%s", match.Span())
+	}
+}
+
+func TestMatchPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + match (x) { 1 => a + b, _ => c }", `(1 + match (x) { 1 => (a + b), _ => c })`},
+		{"match (x) { y if y && z => f(y) }", `match (x) { y if (y && z) => f(y) }`},
+		{`match (x) { "a" => match (y) { _ => 1 }, }`, `match (x) { "a" => match (y) { _ => 1 } }`},
+	}
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.PrintAsString() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.PrintAsString())
+		}
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match x { _ => 1 }", "1:7: expected `(`, found identifier `x`"},
+		{"match (x) { 1 2 }", "1:15: expected `=>`, found integer `2`"},
+		{"match (x) { _ => 1 _ => 2 }", "1:20: expected `}`, found identifier `_`"},
+		{"match (x) { + => 1 }", "1:13: expected a pattern, found `+`"},
+		{"match (x) { - a => 1 }", "1:15: expected a number after - in a pattern, found identifier `a`"},
+		{`match (x) { "${y}" => 1 }`, "1:13: interpolated strings can't be used as patterns"},
+		{"match (x) { ...r => 1 }", "1:13: a rest pattern can only be used inside an array pattern"},
+		{"match (x) { [...a, ...b] => 1 }", "1:20: an array pattern can only have one rest pattern"},
+		{"match (x) { [a, {b: a}] => 1 }", "1:21: duplicate binding a in pattern"},
+		{"match (x) { {[1]: a} => 1 }", "1:14: expected a key, found `[`"},
+		{`match (x) { {"a"} => 1 }`, "1:17: expected `:`, found `}`"},
+		{"match (x) { [a, ...1] => 1 }", "1:20: expected identifier, found integer `1`"},
+		{"match (fn) { true => 1 }", "1:10: expected `(`, found `)`"},
+		{"match () { true => 1 }", "1:8: expected an expression, found `)`"},
+		{"match (x) { a => 1 }; a", ""},
+		{"match (x) { Color.Red => 1 }", "1:18: expected `=>`, found `.`"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+		errors := errorStrings(p)
+		if tt.expected == "" {
+			if len(errors) != 0 {
+				t.Errorf("expected no errors for %q, got=%q", tt.input, errors)
+			}
+			continue
+		}
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestMatchExhaustiveness(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { true => 1 }", "1:1: match is not exhaustive, missing false"},
+		{"match (x) { true => 1, false => 2 }", ""},
+		{"match (x) { true => 1, false if y => 2 }", "1:1: match is not exhaustive, missing false"},
+		{"match (x) { true => 1, _ => 2 }", ""},
+		{"match (x) { true => 1, other => 2 }", ""},
+		{"match (x) { true => 1, other if y => 2 }", "1:1: match is not exhaustive, missing false"},
+		{"match (x) { true => 1, 0 => 2 }", ""},
+		{"match (x) { 1 => 1 }", ""},
+		{"match (x) { false => 1 }", "1:1: match is not exhaustive, missing true"},
+		{"match (x) { true if y => 1, false if y => 2 }", "1:1: match is not exhaustive, missing true, false"},
+		{"match (x) { n if n => 1 }", ""},
+		{"fn f(x) {\n  match (x) { true => 1 }\n}", "2:3: match is not exhaustive, missing false"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+		diagnostics := p.Diagnostics()
+		if tt.expected == "" {
+			if len(diagnostics) != 0 {
+				t.Errorf("expected no diagnostics for %q, got=%q", tt.input, errorStrings(p))
+			}
+			continue
+		}
+		if len(diagnostics) != 1 || diagnostics[0].String() != tt.expected {
+			t.Errorf("wrong diagnostics for %q. expected=%q, got=%q", tt.input, tt.expected, errorStrings(p))
+			continue
+		}
+		if diagnostics[0].Severity != diagnostic.Warning || diagnostics[0].Code != diagnostic.NonExhaustiveMatch {
+			t.Errorf("expected a %s warning for %q, got %s %s", diagnostic.NonExhaustiveMatch, tt.input, diagnostics[0].Severity, diagnostics[0].Code)
+		}
+	}
+}
+
+func TestOptionalChainErrors(t *testing.T) {
+	tests := []string{
+		"a?.1",
+		"a?.[0] = 1",
+		"a?.b = 1",
+	}
+
+	for _, input := range tests {
+		l := lexer.NewLexer(input)
+		p := NewParser(l)
+		p.ParseProgram()
+		if len(p.Diagnostics()) == 0 {
+			t.Errorf("expected parser errors for %s", input)
+		}
+	}
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let = 5;", "1:5: expected identifier, found `=`"},
+		{"let x = 5;\r\nlet y 6;", "2:7: expected `=`, found integer `6`"},
+		{"let x = 1;\n\t)", "2:2: expected an expression, found `)`"},
+		{"\"line\n${}\"", "2:3: empty interpolation in string"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+		errors := errorStrings(p)
+		if len(errors) == 0 || errors[0] != tt.expectedError {
+			t.Errorf("expected error %q, got=%q", tt.expectedError, errors)
+		}
+	}
+}
+
+func TestNodeSpans(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x", "1:1-1:2"},
+		{"let total = a + 10;", "1:1-1:19"},
+		{"  foo(1, 2)", "1:3-1:12"},
+		{"[1, 2][0]", "1:1-1:10"},
+		{"a[1:]", "1:1-1:6"},
+		{`{"a": 1}`, "1:1-1:9"},
+		{"fn add(x, y) {\n  x + y\n}", "1:1-3:2"},
+		{"if (a) { 1 } else if (b) { 2 }", "1:1-1:31"},
+		{"while (a) {\r\n}", "1:1-2:2"},
+		{"for (x in xs) { }", "1:1-1:18"},
+		{"-a?.b", "1:1-1:6"},
+		{"x += 1", "1:1-1:7"},
+		{"return x", "1:1-1:9"},
+		{`"hi ${name}"`, "1:1-1:13"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		span := program.Statements[0].Span()
+		if span.String() != tt.expected {
+			t.Errorf("wrong span for %q. expected=%s, got=%s", tt.input, tt.expected, span)
+		}
+	}
+}
+
+func TestInterpolationSpans(t *testing.T) {
+	input := "let s = \"a\n  ${value}\""
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	concat := program.Statements[0].(*ast.LetStatement).Value.(*ast.ConcatExpression)
+	span := concat.Parts[1].Span()
+	if span.String() != "2:5-2:10" {
+		t.Errorf("wrong span for interpolated value. expected=2:5-2:10, got=%s", span)
+	}
+	if span.Start.Offset != 15 {
+		t.Errorf("wrong offset for interpolated value. expected=15, got=%d", span.Start.Offset)
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	input := `
+/// The answer.
+const answer = 42;
+
+/// Adds two numbers.
+/// Works with floats too.
+fn add(x, y) { x + y }
+
+/// Not attached to anything.
+add(1, 2);
+let plain = 1;
+`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 4 {
+		t.Fatalf("program.Statements does not contain 4 statements. got=%d", len(program.Statements))
+	}
+
+	if doc := program.Statements[0].(*ast.LetStatement).Doc; doc != "The answer." {
+		t.Errorf("wrong doc for const. got=%q", doc)
+	}
+	if doc := program.Statements[1].(*ast.FunctionStatement).Doc; doc != "Adds two numbers.\nWorks with floats too." {
+		t.Errorf("wrong doc for function. got=%q", doc)
+	}
+	if doc := program.Statements[3].(*ast.LetStatement).Doc; doc != "" {
+		t.Errorf("doc comment leaked to a later statement. got=%q", doc)
+	}
+}
+
+func TestCommentErrors(t *testing.T) {
+	input := "let x = 1;\n/* never closed"
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	p.ParseProgram()
+
+	errors := errorStrings(p)
+	if len(errors) != 1 || errors[0] != "2:1: unterminated block comment" {
+		t.Errorf("expected unterminated block comment error, got=%q", errors)
+	}
+}
+
+func TestSyntaxExample(t *testing.T) {
+	input, err := os.ReadFile("../../final_syntax_example.md")
+	if err != nil {
+		t.Fatalf("could not read syntax example: %s", err)
+	}
+
+	l := lexer.NewLexer(string(input))
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 6 {
+		t.Fatalf("program.Statements does not contain 6 statements. got=%d", len(program.Statements))
+	}
+}
+
+func TestNumberLiteralForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"0xFF", int64(255)},
+		{"0b1010", int64(10)},
+		{"0o17", int64(15)},
+		{"1_000_000", int64(1000000)},
+		{"0xdead_beef", int64(0xdeadbeef)},
+		{"1_000.5", 1000.5},
+		{"1.5e-3", 0.0015},
+		{"2E3", 2000.0},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		switch expected := tt.expected.(type) {
+		case int64:
+			integer, ok := stmt.Expression.(*ast.IntegerLiteral)
+			if !ok || integer.Value != expected {
+				t.Errorf("%s: expected integer %d, got=%#v", tt.input, expected, stmt.Expression)
+			}
+		case float64:
+			testFloatLiteral(t, stmt.Expression, expected)
+		}
+	}
+}
+
+func TestMalformedNumberErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let x = 1.2.3;", `1:9: malformed number "1.2.3": too many decimal points`},
+		{"0x + 1", `1:1: malformed number "0x": hexadecimal literal has no digits`},
+		{"[1, 2e]", `1:5: malformed number "2e": exponent has no digits`},
+		{"let a = 1; ¿", `1:12: unexpected character "¿"`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+		errors := errorStrings(p)
+		if len(errors) != 1 || errors[0] != tt.expectedError {
+			t.Errorf("expected only error %q, got=%q", tt.expectedError, errors)
+		}
+	}
+}
+
+func TestBigIntegerLiteral(t *testing.T) {
+	input := "123456789012345678901234567890; 0xFFFF_FFFF_FFFF_FFFF"
+	expected := []string{"123456789012345678901234567890", "18446744073709551615"}
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	for i, value := range expected {
+		stmt := program.Statements[i].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.BigIntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.BigIntegerLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value.String() != value {
+			t.Errorf("literal.Value not %s. got=%s", value, literal.Value)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements int
+	}{
+		{
+			"let = 5;\nlet x 6;\nlet y = 7;\n1 +;\nlet z = ;\nz",
+			[]string{
+				"1:5: expected identifier, found `=`",
+				"2:7: expected `=`, found integer `6`",
+				"4:4: expected an expression, found `;`",
+				"5:9: expected an expression, found `;`",
+			},
+			2,
+		},
+		{
+			"fn f() {\n  let = 1\n  return 2\n}\nlet ok = (1 + 2\nlet w = 3",
+			[]string{
+				"2:7: expected identifier, found `=`",
+				"6:1: expected `)`, found `let`",
+			},
+			2,
+		},
+		{
+			"let d = {1: 2 3: 4}\nlet p = Point{x: {y: 1 z}}\nfn f() { {a: fn() { let }} }\nlet ok = 1",
+			[]string{
+				"1:15: expected `}`, found integer `3`",
+				"2:24: expected `}`, found identifier `z`",
+				"3:25: expected identifier, found `}`",
+			},
+			2,
+		},
+		{
+			"let a = [1, 2\nlet b = {1: }\nif (a { b }\nwhile (true) { let }",
+			[]string{
+				"2:1: expected `]`, found `let`",
+				"2:13: expected an expression, found `}`",
+				"3:7: expected `)`, found `{`",
+				"4:20: expected identifier, found `}`",
+			},
+			1,
+		},
+		{
+			"let x = 1.2.3 + (4 * ; let y = 2",
+			[]string{`1:9: malformed number "1.2.3": too many decimal points`},
+			1,
+		},
+		{
+			"const c = 1; c = 2; break; let = 3",
+			[]string{
+				"1:14: cannot assign to constant c",
+				"1:21: break outside of a loop",
+				"1:32: expected identifier, found `=`",
+			},
+			3,
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+
+		errors := errorStrings(p)
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("%q: wrong number of errors. expected=%d, got=%d: %q", tt.input, len(tt.expectedErrors), len(errors), errors)
+			continue
+		}
+		for i, expected := range tt.expectedErrors {
+			if errors[i] != expected {
+				t.Errorf("%q: error [%d] wrong. expected=%q, got=%q", tt.input, i, expected, errors[i])
+			}
+		}
+		if len(program.Statements) != tt.expectedStatements {
+			t.Errorf("%q: wrong number of statements. expected=%d, got=%d", tt.input, tt.expectedStatements, len(program.Statements))
+		}
+		for i, stmt := range program.Statements {
+			if stmt == nil || reflect.ValueOf(stmt).IsNil() {
+				t.Errorf("%q: statement [%d] is nil", tt.input, i)
+			}
+		}
+	}
+}
+
+func TestErrorLimit(t *testing.T) {
+	input := strings.Repeat("let = 1;\n", maxErrors+10)
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	p.ParseProgram()
+
+	errors := errorStrings(p)
+	if len(errors) != maxErrors+1 {
+		t.Fatalf("expected %d errors, got=%d", maxErrors+1, len(errors))
+	}
+	if errors[maxErrors-1] != fmt.Sprintf("%d:5: expected identifier, found `=`", maxErrors) {
+		t.Errorf("wrong last error, got=%q", errors[maxErrors-1])
+	}
+	if errors[maxErrors] != fmt.Sprintf("too many errors, stopping after %d", maxErrors) {
+		t.Errorf("wrong limit message, got=%q", errors[maxErrors])
+	}
+}
+
+func TestDiagnosticCodes(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedCode diagnostic.Code
+		expectedSpan string
+	}{
+		{"let 5 = x", diagnostic.UnexpectedToken, "1:5-1:6"},
+		{"let x = ;", diagnostic.ExpectedExpression, "1:9-1:10"},
+		{"1 = 2", diagnostic.InvalidAssignmentTarget, "1:1-1:2"},
+		{`"${}"`, diagnostic.InvalidInterpolation, "1:4-1:4"},
+		{`"\q"`, diagnostic.InvalidLiteral, "1:1-1:5"},
+		{"continue", diagnostic.LoopControlOutsideLoop, "1:1-1:9"},
+		{"const a = 1; a = 2", diagnostic.AssignToConstant, "1:14-1:19"},
+		{"const a = 1; const a = 2", diagnostic.RedeclaredConstant, "1:20-1:21"},
+		{"let a = 0x", diagnostic.MalformedNumber, "1:9-1:11"},
+		{"let a = \"open", diagnostic.UnterminatedString, "1:9-1:10"},
+		{"fn f() {\n  1", diagnostic.UnclosedBlock, "2:4-2:4"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Errorf("%q: expected 1 diagnostic, got=%v", tt.input, diagnostics)
+			continue
+		}
+		if diagnostics[0].Code != tt.expectedCode {
+			t.Errorf("%q: wrong code. expected=%s, got=%s", tt.input, tt.expectedCode, diagnostics[0].Code)
+		}
+		if diagnostics[0].Span.String() != tt.expectedSpan {
+			t.Errorf("%q: wrong span. expected=%s, got=%s", tt.input, tt.expectedSpan, diagnostics[0].Span)
+		}
+	}
+
+	p := NewParser(lexer.NewLexer("fn f() {\n  1"))
+	p.ParseProgram()
+	opened := p.Diagnostics()[0].Secondary
+	if len(opened) != 1 || opened[0].Span.String() != "1:8-1:9" || opened[0].Message != "block opened here" {
+		t.Errorf("expected the opening brace as secondary span, got=%v", opened)
+	}
+}
@@ -0,0 +1,296 @@
+package parser
+
+import (
+	"af/src/ast"
+	"af/src/diagnostic"
+	"af/src/token"
+	"strconv"
+	"strings"
+)
+
+// parses match (value) { pattern if guard => body, ... }, a trailing comma is allowed
+func (p *Parser) parseMatchExpression() ast.Expression {
+	match := &ast.MatchExpression{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	match.Value = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	p.openBraces++
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		arm, ok := p.parseMatchArm()
+		if !ok {
+			return nil
+		}
+		match.Arms = append(match.Arms, arm)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	p.openBraces--
+	match.EndToken = p.curToken
+	// after a syntax error the value may be missing, the arms can't be checked against it
+	if match.Value != nil && !p.panicking {
+		p.checkExhaustive(match)
+	}
+	return match
+}
+
+// the names bound by the pattern can be used in the guard and the body of the arm
+func (p *Parser) parseMatchArm() (ast.MatchArm, bool) {
+	arm := ast.MatchArm{Pattern: p.parsePattern()}
+	if arm.Pattern == nil {
+		return arm, false
+	}
+	p.pushScope()
+	defer p.popScope()
+	p.declarePattern(arm.Pattern, nil)
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		arm.Guard = p.parseExpression(LOWEST)
+		if arm.Guard == nil {
+			return arm, false
+		}
+	}
+	if !p.expectPeek(token.ARROW) {
+		return arm, false
+	}
+	p.nextToken()
+	arm.Body = p.parseExpression(LOWEST)
+	return arm, arm.Body != nil
+}
+
+// parses the pattern starting at the current token
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.curToken.Type {
+	case token.IDENT:
+		identifier := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if identifier.Value == "_" {
+			return &ast.WildcardPattern{Token: p.curToken}
+		}
+		return &ast.BindingPattern{Name: identifier}
+	case token.INT, token.FLOAT, token.STRING, token.TRUE, token.FALSE, token.NULL:
+		value := p.parseLiteralPattern()
+		if value == nil {
+			return nil
+		}
+		return &ast.ValuePattern{Value: value}
+	case token.MINUS:
+		negative := &ast.PrefixExpression{Token: p.curToken, Operator: p.curToken.Literal}
+		if !p.peekTokenIs(token.INT) && !p.peekTokenIs(token.FLOAT) {
+			p.errorAt(p.peekToken.Span, diagnostic.InvalidPattern, "expected a number after - in a pattern, found %s", p.peekToken.Describe())
+			return nil
+		}
+		p.nextToken()
+		negative.Right = p.parseLiteralPattern()
+		if negative.Right == nil {
+			return nil
+		}
+		return &ast.ValuePattern{Value: negative}
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	case token.ELLIPSIS:
+		p.errorAt(p.curToken.Span, diagnostic.InvalidPattern, "a rest pattern can only be used inside an array pattern")
+		return nil
+	default:
+		p.errorAt(p.curToken.Span, diagnostic.InvalidPattern, "expected a pattern, found %s", p.curToken.Describe())
+		return nil
+	}
+}
+
+// a literal without interpolation, so the pattern always matches the same value
+func (p *Parser) parseLiteralPattern() ast.Expression {
+	value := p.prefixParserFns[p.curToken.Type]()
+	if _, ok := value.(*ast.ConcatExpression); ok {
+		p.errorAt(p.curToken.Span, diagnostic.InvalidPattern, "interpolated strings can't be used as patterns")
+		return nil
+	}
+	return value
+}
+
+// parses [first, ...rest, last], only one rest pattern is allowed
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+	var rest *ast.RestPattern
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		var element ast.Pattern
+		if p.curTokenIs(token.ELLIPSIS) {
+			restPattern := &ast.RestPattern{Token: p.curToken}
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			restPattern.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if rest != nil {
+				d := diagnostic.Errorf(diagnostic.InvalidPattern, restPattern.Span(), "an array pattern can only have one rest pattern")
+				d.Secondary = []diagnostic.Label{{Span: rest.Span(), Message: "first rest pattern here"}}
+				p.reportSemantic(d)
+			}
+			rest = restPattern
+			element = restPattern
+		} else {
+			element = p.parsePattern()
+			if element == nil {
+				return nil
+			}
+		}
+		pattern.Elements = append(pattern.Elements, element)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	pattern.EndToken = p.curToken
+	return pattern
+}
+
+// parses {"key": pattern, name: pattern, name}, a bare name binds the value of the key with that name
+func (p *Parser) parseHashPattern() ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.curToken}
+	p.openBraces++
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		var key ast.Expression
+		switch p.curToken.Type {
+		case token.IDENT:
+			key = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.peekTokenIs(token.COLON) {
+				name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+				pattern.Pairs = append(pattern.Pairs, ast.HashPatternPair{Key: key, Value: &ast.BindingPattern{Name: name}})
+			}
+		case token.STRING, token.INT, token.TRUE, token.FALSE:
+			key = p.parseLiteralPattern()
+			if key == nil {
+				return nil
+			}
+		default:
+			p.errorAt(p.curToken.Span, diagnostic.InvalidPattern, "expected a key, found %s", p.curToken.Describe())
+			return nil
+		}
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			value := p.parsePattern()
+			if value == nil {
+				return nil
+			}
+			pattern.Pairs = append(pattern.Pairs, ast.HashPatternPair{Key: key, Value: value})
+		} else if !p.curTokenIs(token.IDENT) {
+			p.peekError(token.COLON)
+			return nil
+		}
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	p.openBraces--
+	pattern.EndToken = p.curToken
+	return pattern
+}
+
+// declares the names bound by the pattern in the current scope, constant is the const
+// statement binding them or nil. A name can only be bound once
+func (p *Parser) declarePattern(pattern ast.Pattern, constant *ast.LetStatement) {
+	bound := map[string]*ast.Identifier{}
+	for _, name := range patternBindings(pattern) {
+		if previous, ok := bound[name.Value]; ok {
+			p.duplicateError(diagnostic.DuplicateBinding, "binding", name, previous, "pattern")
+			continue
+		}
+		bound[name.Value] = name
+		p.declare(name, constant)
+	}
+}
+
+// names bound by the pattern in the order they are written
+func patternBindings(pattern ast.Pattern) []*ast.Identifier {
+	switch pattern := pattern.(type) {
+	case *ast.BindingPattern:
+		return []*ast.Identifier{pattern.Name}
+	case *ast.RestPattern:
+		if pattern.Name.Value == "_" {
+			return nil
+		}
+		return []*ast.Identifier{pattern.Name}
+	case *ast.ArrayPattern:
+		names := []*ast.Identifier{}
+		for _, element := range pattern.Elements {
+			names = append(names, patternBindings(element)...)
+		}
+		return names
+	case *ast.HashPattern:
+		names := []*ast.Identifier{}
+		for _, pair := range pattern.Pairs {
+			names = append(names, patternBindings(pair.Value)...)
+		}
+		return names
+	default:
+		return nil
+	}
+}
+
+// warns when every arm matches a boolean and true or false is left out. A _ or binding arm
+// without guard makes the match exhaustive, arms with a guard don't cover their value
+// as the guard may be false
+func (p *Parser) checkExhaustive(match *ast.MatchExpression) {
+	booleans := false
+	covered := map[bool]bool{}
+	for _, arm := range match.Arms {
+		switch pattern := arm.Pattern.(type) {
+		case *ast.WildcardPattern, *ast.BindingPattern:
+			if arm.Guard == nil {
+				return
+			}
+		case *ast.ValuePattern:
+			boolean, ok := pattern.Value.(*ast.Boolean)
+			if !ok {
+				return
+			}
+			booleans = true
+			if arm.Guard == nil {
+				covered[boolean.Value] = true
+			}
+		default:
+			return
+		}
+	}
+	if !booleans {
+		return
+	}
+
+	missing := []string{}
+	for _, value := range []bool{true, false} {
+		if !covered[value] {
+			missing = append(missing, strconv.FormatBool(value))
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	d := diagnostic.Diagnostic{
+		Severity: diagnostic.Warning,
+		Code:     diagnostic.NonExhaustiveMatch,
+		Message:  "match is not exhaustive, missing " + strings.Join(missing, ", "),
+		Span:     token.Span{Start: match.Token.Span.Start, End: match.Value.Span().End},
+		Help:     "add an arm for each missing value or a _ arm",
+	}
+	p.reportSemantic(d)
+}
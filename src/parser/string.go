@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"af/src/ast"
+	"af/src/diagnostic"
+	"af/src/lexer"
+	"af/src/token"
+)
+
+// a string without interpolations becomes a StringLiteral, otherwise the text
+// and ${...} parts are collected into a ConcatExpression
+func (p *Parser) parseStringLiteral() ast.Expression {
+	raw := p.curToken.Literal
+	parts := []ast.Expression{}
+	interpolated := false
+	textStart := 0
+
+	for i := 0; i < len(raw); i++ {
+		switch {
+		case raw[i] == '\\':
+			i++
+		case raw[i] == '$' && i+1 < len(raw) && raw[i+1] == '{':
+			if text := p.parseStringText(raw[textStart:i]); text != nil {
+				parts = append(parts, text)
+			}
+			end := matchingBrace(raw, i+2)
+			if end < 0 {
+				p.errorAt(p.curToken.Span, diagnostic.InvalidInterpolation, "unterminated interpolation in string %q", raw)
+				return nil
+			}
+			expression := p.parseInterpolation(raw[i+2:end], p.positionInString(raw[:i+2]))
+			if expression == nil {
+				return nil
+			}
+			parts = append(parts, expression)
+			interpolated = true
+			i = end
+			textStart = end + 1
+		}
+	}
+
+	if !interpolated {
+		value, err := lexer.Unescape(raw)
+		if err != nil {
+			p.errorAt(p.curToken.Span, diagnostic.InvalidLiteral, "could not parse string %q: %s", raw, err)
+			return nil
+		}
+		return &ast.StringLiteral{Token: p.curToken, Value: value}
+	}
+	if text := p.parseStringText(raw[textStart:]); text != nil {
+		parts = append(parts, text)
+	}
+	return &ast.ConcatExpression{Token: p.curToken, Parts: parts}
+}
+
+// returns nil for empty text, so interpolations don't get empty parts
+func (p *Parser) parseStringText(raw string) ast.Expression {
+	if raw == "" {
+		return nil
+	}
+	value, err := lexer.Unescape(raw)
+	if err != nil {
+		p.errorAt(p.curToken.Span, diagnostic.InvalidLiteral, "could not parse string %q: %s", raw, err)
+		return nil
+	}
+	return &ast.StringLiteral{Token: p.curToken, Value: value}
+}
+
+// the source between ${ and } is parsed as a single expression with its own parser,
+// start is the position of the source inside the file so errors and spans point at it
+func (p *Parser) parseInterpolation(source string, start token.Position) ast.Expression {
+	inner := NewParser(lexer.NewLexerAt(source, start))
+	inner.scopes = p.scopes
+	if inner.curTokenIs(token.EOF) {
+		p.errorAt(token.Span{Start: start, End: start}, diagnostic.InvalidInterpolation, "empty interpolation in string")
+		return nil
+	}
+	expression := inner.parseExpression(LOWEST)
+	if !inner.peekTokenIs(token.EOF) && len(inner.diagnostics) == 0 {
+		inner.errorAt(inner.peekToken.Span, diagnostic.InvalidInterpolation, "unexpected %s in string interpolation", inner.peekToken.Describe())
+	}
+	if len(inner.diagnostics) > 0 {
+		for _, d := range inner.diagnostics {
+			p.addDiagnostic(d)
+		}
+		p.panicking = p.panicking || inner.panicking
+		return nil
+	}
+	return expression
+}
+
+// position in the file of the char following prefix, a prefix of the current string token content
+func (p *Parser) positionInString(prefix string) token.Position {
+	return p.curToken.Span.Start.Advance("\"" + prefix)
+}
+
+// finds the '}' that closes an interpolation starting at start, skipping nested braces and
+// strings the way the lexer does, so a string with its own interpolations can be nested
+func matchingBrace(raw string, start int) int {
+	depth := 1
+	for i := start; i < len(raw); i++ {
+		switch raw[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		case '"':
+			i = closingQuote(raw, i+1)
+			if i < 0 {
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+// finds the '"' that ends a string whose content starts at start, skipping escapes and interpolations
+func closingQuote(raw string, start int) int {
+	for i := start; i < len(raw); i++ {
+		switch {
+		case raw[i] == '\\':
+			i++
+		case raw[i] == '"':
+			return i
+		case raw[i] == '$' && i+1 < len(raw) && raw[i+1] == '{':
+			i = matchingBrace(raw, i+2)
+			if i < 0 {
+				return -1
+			}
+		}
+	}
+	return -1
+}
@@ -1,8 +1,11 @@
 package repl
 
 import (
+	"af/src/diagnostic"
+	"af/src/evaluator"
 	"af/src/lexer"
-	"af/src/token"
+	"af/src/object"
+	"af/src/parser"
 	"bufio"
 	"fmt"
 	"io"
@@ -12,6 +15,7 @@ const PROMPT = "AF >> "
 
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
+	env := object.NewEnvironment()
 	for {
 		fmt.Fprintf(out, PROMPT)
 		scanned := scanner.Scan()
@@ -20,8 +24,23 @@ func Start(in io.Reader, out io.Writer) {
 		}
 		line := scanner.Text()
 		l := lexer.NewLexer(line)
-		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-			fmt.Fprintf(out, "%+v\n", tok)
+		p := parser.NewParser(l)
+
+		program := p.ParseProgram()
+		// warnings are shown but don't stop the line from running
+		io.WriteString(out, diagnostic.RenderAll(line, p.Diagnostics()))
+		if diagnostic.HasErrors(p.Diagnostics()) {
+			continue
+		}
+
+		evaluated := evaluator.Eval(program, env)
+		if err, ok := evaluated.(*object.Error); ok {
+			io.WriteString(out, err.Diagnostic().Render(line))
+			continue
+		}
+		if evaluated != nil {
+			io.WriteString(out, evaluated.Inspect())
+			io.WriteString(out, "\n")
 		}
 	}
 }
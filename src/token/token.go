@@ -1,17 +1,33 @@
 package token
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
 
 type TokenType string
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"if":     IF,
-	"else":   ELSE,
-	"true":   TRUE,
-	"false":  FALSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"const":    CONST,
+	"if":       IF,
+	"else":     ELSE,
+	"true":     TRUE,
+	"false":    FALSE,
+	"null":     NULL,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"struct":   STRUCT,
+	"class":    CLASS,
+	"extends":  EXTENDS,
+	"super":    SUPER,
+	"match":    MATCH,
 }
 
 func LookUpIdent(ident string) TokenType {
@@ -21,8 +37,46 @@ func LookUpIdent(ident string) TokenType {
 	return IDENT
 }
 
+// names used for token types in error messages, other types are shown as they are written
+var names = map[TokenType]string{
+	EOF:         "end of input",
+	ILLEGAL:     "illegal token",
+	IDENT:       "identifier",
+	INT:         "integer",
+	FLOAT:       "float",
+	STRING:      "string",
+	DOC_COMMENT: "doc comment",
+}
+
+// human friendly name of a token type: identifier, `let`, `+=`
+func Describe(tokenType TokenType) string {
+	if name, ok := names[tokenType]; ok {
+		return name
+	}
+	for keyword, keywordType := range keywords {
+		if keywordType == tokenType {
+			return "`" + keyword + "`"
+		}
+	}
+	return "`" + string(tokenType) + "`"
+}
+
+// like Describe, adding the text of identifiers and literals: identifier `foo`, integer `5`
+func (t Token) Describe() string {
+	switch t.Type {
+	case IDENT, INT, FLOAT, ILLEGAL:
+		return Describe(t.Type) + " `" + t.Literal + "`"
+	case STRING:
+		return Describe(t.Type) + " \"" + t.Literal + "\""
+	default:
+		return Describe(t.Type)
+	}
+}
+
+// decimals with a fraction or an exponent are floats, hexadecimal digits can be an 'e'
 func LookUpNumberType(ident string) TokenType {
-	if strings.Contains(ident, ".") {
+	lower := strings.ToLower(ident)
+	if !strings.HasPrefix(lower, "0x") && strings.ContainsAny(lower, ".e") {
 		return FLOAT
 	}
 	return INT
@@ -33,9 +87,12 @@ const (
 	ILLEGAL = "ILLEGAL"
 
 	// Variables
-	IDENT = "IDENT"
-	INT   = "INT"
-	FLOAT = "FLOAT"
+	IDENT  = "IDENT"
+	INT    = "INT"
+	FLOAT  = "FLOAT"
+	STRING = "STRING"
+
+	DOC_COMMENT = "DOC_COMMENT" // /// text attached to the following declaration
 
 	// Operators
 	ASSIGN   = "="
@@ -45,33 +102,104 @@ const (
 	MOD      = "%"
 	BANG     = "!"
 	ASTERISK = "*"
+	POWER    = "**"
+
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+	MOD_ASSIGN      = "%="
 
 	LT        = "<"
 	GT        = ">"
+	LT_EQ     = "<="
+	GT_EQ     = ">="
 	EQUAL     = "=="
 	NOT_EQUAL = "!="
+	AND       = "&&"
+	OR        = "||"
+
+	NULLISH        = "??"
+	OPTIONAL_CHAIN = "?."
+
+	ARROW    = "=>"
+	ELLIPSIS = "..."
 
 	// Delimeters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
 	DOT       = "."
 
-	LPAREN = "("
-	RPAREN = ")"
-	LBRACE = "{"
-	RBRACE = "}"
+	LPAREN   = "("
+	RPAREN   = ")"
+	LBRACE   = "{"
+	RBRACE   = "}"
+	LBRACKET = "["
+	RBRACKET = "]"
 
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	NULL     = "NULL"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	STRUCT   = "STRUCT"
+	CLASS    = "CLASS"
+	EXTENDS  = "EXTENDS"
+	SUPER    = "SUPER"
+	MATCH    = "MATCH"
 )
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Span    Span
+}
+
+// location of a char in the source, Line and Column start at 1
+type Position struct {
+	Offset int // bytes from the start of the input
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Advance returns the position found after reading text from p,
+// a \r\n pair counts as a single line break and columns count runes, not bytes
+func (p Position) Advance(text string) Position {
+	for i := 0; i < len(text); {
+		char, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		p.Offset += size
+		if char == '\n' || char == '\r' && (i >= len(text) || text[i] != '\n') {
+			p.Line++
+			p.Column = 1
+		} else {
+			p.Column++
+		}
+	}
+	return p
+}
+
+// region of the source between Start and End, End is the position right after the last char
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func (s Span) String() string {
+	return s.Start.String() + "-" + s.End.String()
 }